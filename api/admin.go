@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/admin"
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/health"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/numbering"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/render"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/vod"
+	"template-go-vercel/pkg/web"
+)
+
+// Admin serves the channel administration page. vercel.json rewrites
+// /admin here. Browsers log in with basic auth using ADMIN_TOKEN as the
+// password. ?health=1 probes every stream.
+func Admin(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "admin", serveAdmin, http.MethodGet, http.MethodPost)
+}
+
+func serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireAdmin(w, r) {
+		return
+	}
+
+	s := store.FromEnv()
+	channels, err := adminChannels(r)
+	if err != nil {
+		logging.From(r.Context()).Error("loading channels failed", "error", err)
+		render.Error(w, r, fetch.HTTPStatus(err), "Error loading channels from MEDIA_URL: "+fetch.Message(err))
+		return
+	}
+	set, err := override.Load(r.Context(), s)
+	if err != nil {
+		render.Error(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+	rows := admin.Rows(channels, set, time.Now())
+
+	if r.Method == http.MethodPost {
+		if !sameOrigin(r) {
+			render.Error(w, r, http.StatusForbidden, "Cross-origin request refused.")
+			return
+		}
+		if !store.Durable(s) {
+			render.Error(w, r, http.StatusServiceUnavailable, "Changes cannot be saved: set REDIS_URL or STORE_DIR to keep them.")
+			return
+		}
+		// The action is applied to the overrides as they are when saving,
+		// so edits made meanwhile in another tab or via the API are kept.
+		var msg string
+		_, err := override.Update(r.Context(), s, func(set override.Set) error {
+			var err error
+			msg, err = applyAdminAction(r, admin.Rows(channels, set, time.Now()), set)
+			return err
+		})
+		q := url.Values{}
+		if err != nil {
+			q.Set("error", err.Error())
+		} else {
+			q.Set("msg", msg)
+		}
+		http.Redirect(w, r, r.URL.Path+"?"+q.Encode(), http.StatusSeeOther)
+		return
+	}
+
+	base := web.BaseURL(r)
+	page := &admin.Page{
+		Rows:        rows,
+		PlaylistURL: base + "/M3U",
+		GuideURL:    base + "/api/xmltv",
+		VODURL:      base + "/VOD",
+		Private:     token.Enabled(),
+		Message:     r.URL.Query().Get("msg"),
+		Error:       r.URL.Query().Get("error"),
+	}
+	if r.URL.Query().Get("health") == "1" {
+		if err := probeRows(r, page.Rows); err != nil {
+			page.Error = fmt.Sprintf("Error probing streams: %v", err)
+		} else {
+			page.Checked = true
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	render.HTML(w, r, http.StatusOK, "admin", page)
+}
+
+// adminChannels loads the live channels with their assigned numbers, before
+// overrides are applied.
+func adminChannels(r *http.Request) ([]source.Channel, error) {
+	src, err := source.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	channels, _ = vod.Split(channels)
+	numberer, err := numbering.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := numberer.Assign(r.Context(), channels); err != nil {
+		logging.From(r.Context()).Warn("assigning channel numbers failed", "error", err)
+	}
+	return channels, nil
+}
+
+// sameOrigin refuses form posts from other sites, which browsers would
+// otherwise send with the cached basic auth credentials.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// applyAdminAction updates set with the form's action and returns a
+// message describing the change.
+func applyAdminAction(r *http.Request, rows []admin.Row, set override.Set) (string, error) {
+	id := r.PostFormValue("id")
+	idx := -1
+	for i, row := range rows {
+		if row.ID == id {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return "", fmt.Errorf("unknown channel %q", id)
+	}
+	row := rows[idx]
+	o := set[id]
+
+	switch action := r.PostFormValue("action"); action {
+	case "save":
+		o.Name = ""
+		if name := strings.TrimSpace(r.PostFormValue("name")); name != row.Feed.Name {
+			o.Name = name
+		}
+		o.Group = ""
+		if group := strings.TrimSpace(r.PostFormValue("group")); group != row.Feed.GroupName() {
+			o.Group = group
+		}
+		o.Number = nil
+		if v := r.PostFormValue("number"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return "", fmt.Errorf("invalid number %q", v)
+			}
+			if n != row.Feed.Number {
+				o.Number = &n
+			}
+		}
+	case "hide":
+		o.Hidden = true
+	case "show":
+		o.Hidden = false
+	case "reset":
+		delete(set, id)
+		return "Restored " + row.Feed.Name, nil
+	case "up", "down":
+		other := idx - 1
+		if action == "down" {
+			other = idx + 1
+		}
+		if other < 0 || other >= len(rows) {
+			return "", fmt.Errorf("%s cannot move %s", row.Channel.Name, action)
+		}
+		mine, theirs := row.Channel.Number, rows[other].Channel.Number
+		if mine == theirs {
+			mine, theirs = theirs+other-idx, mine
+		} else {
+			mine, theirs = theirs, mine
+		}
+		neighbour := set[rows[other].ID]
+		neighbour.Number = &theirs
+		set[rows[other].ID] = neighbour
+		o.Number = &mine
+	default:
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+
+	if o.IsZero() {
+		delete(set, id)
+	} else {
+		set[id] = o
+	}
+	return "Saved " + row.Channel.Name, nil
+}
+
+func probeRows(r *http.Request, rows []admin.Row) error {
+	checker, err := health.NewChecker()
+	if err != nil {
+		return err
+	}
+	targets := make([]health.Target, len(rows))
+	for i, row := range rows {
+		targets[i] = health.Target{ID: row.ID, Name: row.Channel.Name, URL: row.Channel.StreamURL}
+	}
+	for i, res := range checker.CheckAll(r.Context(), targets) {
+		res := res
+		rows[i].Health = &res
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"net/http"
+
+	"template-go-vercel/pkg/catchup"
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/ratelimit"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+)
+
+// Catchup redirects to a recording of a past programme. vercel.json
+// rewrites /api/catchup/{channelId} here; start and end accept unix
+// seconds, RFC 3339 or XMLTV timestamps.
+func Catchup(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "catchup", serveCatchup)
+}
+
+func serveCatchup(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, err := catchup.ParseTime(q.Get("start"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := catchup.ParseTime(q.Get("end"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !end.After(start) {
+		http.Error(w, "end must be after start", http.StatusBadRequest)
+		return
+	}
+	cfg := catchup.FromEnv()
+	if _, err := cfg.Check(start, end); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile, tok, err := token.Authorize(r)
+	if err != nil {
+		http.Error(w, err.Error(), token.Status(err))
+		return
+	}
+	if !ratelimit.Check(w, r, "catchup", tok) {
+		return
+	}
+
+	src, err := source.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		fetch.Fail(w, r, "loading channels from MEDIA_URL", err)
+		return
+	}
+
+	// Recordings are only resolved for channels the caller may watch in
+	// full, the same decision /api/stream makes for live streams.
+	id := q.Get("id")
+	channels = override.FromStore(r.Context(), store.FromEnv(), channels)
+	var entitled []source.Channel
+	for _, ch := range channels {
+		d := policy.Decide(ch, profile)
+		if d.Include && d.Variant == policy.Normal {
+			entitled = append(entitled, ch)
+		} else if ch.ID == id {
+			http.Error(w, d.Reason, http.StatusForbidden)
+			return
+		}
+	}
+
+	target, err := cfg.Resolve(entitled, id, start, end)
+	switch err {
+	case nil:
+		http.Redirect(w, r, target, http.StatusFound)
+	case catchup.ErrOutOfRange:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case catchup.ErrUnknownChannel, catchup.ErrNotAvailable:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
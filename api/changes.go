@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/changes"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+)
+
+type changesResponse struct {
+	Entries []changes.Entry `json:"entries"`
+}
+
+// Changes lists lineup changes, newest first. ?since= (RFC 3339) and
+// ?limit= (default 50) narrow the list. Stream URLs are only shown to
+// admins.
+func Changes(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "changes", serveChanges)
+}
+
+func serveChanges(w http.ResponseWriter, r *http.Request) {
+	if _, _, err := token.Authorize(r); err != nil && !auth.Admin(r) {
+		http.Error(w, err.Error(), token.Status(err))
+		return
+	}
+
+	q := r.URL.Query()
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > changes.MaxHistory {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", changes.MaxHistory), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	history, err := changes.History(r.Context(), store.FromEnv())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	admin := auth.Admin(r)
+	resp := changesResponse{Entries: []changes.Entry{}}
+	for _, e := range history {
+		if len(resp.Entries) == limit || !e.At.After(since) {
+			break
+		}
+		if !admin {
+			e.Changes = redactURLs(e.Changes)
+		}
+		resp.Entries = append(resp.Entries, e)
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error happened in JSON marshal: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// redactURLs returns list without stream URLs, which would bypass
+// private playlists.
+func redactURLs(list []changes.Change) []changes.Change {
+	out := make([]changes.Change, len(list))
+	for i, c := range list {
+		switch c.Kind {
+		case changes.Added, changes.Removed, changes.URLChanged:
+			c.Old, c.New = "", ""
+		}
+		out[i] = c
+	}
+	return out
+}
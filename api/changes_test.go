@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"template-go-vercel/pkg/changes"
+)
+
+func TestPlaylistRecordsChanges(t *testing.T) {
+	xtreamEnv(t)
+	t.Setenv("STORE_DIR", t.TempDir())
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	feed := filepath.Join(t.TempDir(), "feed.json")
+	t.Setenv("MEDIA_URL", feed)
+
+	pull := func(handler http.HandlerFunc, target, lineup string) {
+		t.Helper()
+		if err := os.WriteFile(feed, []byte(lineup), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", target, w.Code, w.Body)
+		}
+	}
+	// Each playlist or guide pull compares the lineup it parsed, without
+	// waiting for the scheduler.
+	pull(M3u, "/api/m3u", `[{"id": "tvj", "name": "TVJ", "url": "https://cdn.example.com/tvj.m3u8"}]`)
+	pull(M3u, "/api/m3u", `[{"id": "tvj", "name": "TVJ HD", "url": "https://cdn.example.com/tvj.m3u8"}]`)
+	pull(XMLTV, "/api/xmltv", `[{"id": "tvj", "name": "TVJ HD", "url": "https://cdn.example.com/tvj.m3u8"},
+		{"id": "cvm", "name": "CVM", "url": "https://cdn.example.com/cvm.m3u8"}]`)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/changes", nil)
+	r.Header.Set("Authorization", "Bearer admin-secret")
+	w := httptest.NewRecorder()
+	Changes(w, r)
+	var resp changesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if len(resp.Entries) != 2 || resp.Entries[0].Changes[0].Kind != changes.Added || resp.Entries[1].Changes[0].Kind != changes.Renamed {
+		t.Errorf("got %+v, want the rename and then the added channel", resp.Entries)
+	}
+}
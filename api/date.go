@@ -4,9 +4,15 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"template-go-vercel/pkg/middleware"
 )
 
 func Date(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "date", serveDate)
+}
+
+func serveDate(w http.ResponseWriter, r *http.Request) {
 	currentTime := time.Now().Format(time.RFC850)
 	fmt.Fprint(w, currentTime)
 }
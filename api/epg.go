@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/ical"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/ratelimit"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/vod"
+)
+
+// Epg exports a channel's schedule as an iCalendar feed. vercel.json
+// rewrites /api/epg/{channelId}.ics here. ?title= keeps only programmes
+// whose title contains the given text.
+func Epg(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "epg", serveEpg)
+}
+
+func serveEpg(w http.ResponseWriter, r *http.Request) {
+	profile, tok, err := token.Authorize(r)
+	if err != nil {
+		http.Error(w, err.Error(), token.Status(err))
+		return
+	}
+	if !ratelimit.Check(w, r, "epg", tok) {
+		return
+	}
+
+	q := r.URL.Query()
+	id := strings.TrimSuffix(q.Get("id"), ".ics")
+	if id == "" {
+		http.Error(w, "missing channel id", http.StatusBadRequest)
+		return
+	}
+
+	src, err := source.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		fetch.Fail(w, r, "loading channels from MEDIA_URL", err)
+		return
+	}
+	channels, _ = vod.Split(channels)
+	channels = override.FromStore(r.Context(), store.FromEnv(), channels)
+	channels, _ = policy.Apply(channels, profile)
+
+	for _, ch := range channels {
+		if ch.ID != id {
+			continue
+		}
+		ch.Programmes = ical.Filter(ch.Programmes, q.Get("title"))
+		cal := &ical.Calendar{Channel: ch, Now: time.Now()}
+		w.Header().Set("Content-Type", ical.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", id+".ics"))
+		w.Write(cal.Marshal())
+		return
+	}
+	http.Error(w, "channel not found", http.StatusNotFound)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/guide"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/logo"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/numbering"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/ratelimit"
+	"template-go-vercel/pkg/render"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/vod"
+	"template-go-vercel/pkg/web"
+)
+
+// Guide renders the TV guide as an HTML grid. vercel.json rewrites /guide
+// here. ?day=YYYY-MM-DD picks the day and ?print=1 lists programmes per
+// channel for printing.
+func Guide(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "guide", serveGuide)
+}
+
+func serveGuide(w http.ResponseWriter, r *http.Request) {
+	profile, tok, err := token.Authorize(r)
+	if err != nil {
+		render.Error(w, r, token.Status(err), err.Error())
+		return
+	}
+	if !ratelimit.Check(w, r, "guide", tok) {
+		return
+	}
+
+	q := r.URL.Query()
+	loc := guide.Location()
+	now := time.Now().In(loc)
+	day := now
+	if v := q.Get("day"); v != "" {
+		d, err := time.ParseInLocation(guide.DayFormat, v, loc)
+		if err != nil {
+			render.Error(w, r, http.StatusBadRequest, "The day must be written as YYYY-MM-DD.")
+			return
+		}
+		day = d
+	}
+
+	src, err := source.FromEnv()
+	if err != nil {
+		render.Error(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		render.Error(w, r, fetch.HTTPStatus(err), "The channel list is not available right now.")
+		logging.From(r.Context()).Error("loading channels from MEDIA_URL failed", "error", err)
+		return
+	}
+
+	channels, _ = vod.Split(channels)
+	numberer, err := numbering.FromEnv()
+	if err != nil {
+		render.Error(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := numberer.Assign(r.Context(), channels); err != nil {
+		logging.From(r.Context()).Warn("assigning channel numbers failed", "error", err)
+	}
+	channels = override.FromStore(r.Context(), store.FromEnv(), channels)
+	channels, _ = policy.Apply(channels, profile)
+
+	if logo.Enabled(q) {
+		logo.Rewrite(channels, web.BaseURL(r), logo.DefaultSize())
+	}
+
+	page := &guide.Page{
+		Grid:  guide.Build(channels, day, now),
+		Day:   q.Get("day"),
+		Print: q.Get("print") == "1",
+		Token: tok,
+	}
+	if tok != "" {
+		w.Header().Set("Cache-Control", "private, max-age=60")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	}
+	render.HTML(w, r, http.StatusOK, "guide", page)
+}
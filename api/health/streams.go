@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/health"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/vod"
+)
+
+// Streams probes every channel from the configured source and returns a
+// JSON health report. Add ?down=1 to list only failing channels. Callers
+// need a playlist token when the playlists are private, and stream URLs are
+// only shown to admins.
+func Streams(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "health_streams", serveStreams)
+}
+
+func serveStreams(w http.ResponseWriter, r *http.Request) {
+	admin := auth.Admin(r)
+	if _, _, err := token.Authorize(r); err != nil && !admin {
+		http.Error(w, err.Error(), token.Status(err))
+		return
+	}
+
+	src, err := source.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	checker, err := health.NewChecker()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error configuring stream probes: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		fetch.Fail(w, r, "loading channels from MEDIA_URL", err)
+		return
+	}
+
+	channels, _ = vod.Split(channels)
+	targets := make([]health.Target, len(channels))
+	for i, ch := range channels {
+		targets[i] = health.Target{ID: ch.ID, Name: ch.Name, URL: ch.StreamURL}
+	}
+	results := checker.CheckAll(r.Context(), targets)
+	if !admin {
+		for i := range results {
+			results[i].URL = ""
+		}
+	}
+
+	if r.URL.Query().Get("down") == "1" {
+		var down []health.Result
+		for _, res := range results {
+			if !res.Up() {
+				down = append(down, res)
+			}
+		}
+		results = down
+	}
+
+	report := health.NewReport(results)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.From(r.Context()).Error("JSON marshal failed", "error", err)
+	}
+}
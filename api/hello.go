@@ -3,8 +3,14 @@ package handler
 import (
 	"fmt"
 	"net/http"
+
+	"template-go-vercel/pkg/middleware"
 )
 
 func Hello(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "hello", serveHello)
+}
+
+func serveHello(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Hello World!")
 }
@@ -1,21 +1,16 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
+
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/render"
 )
 
 func HtmlRendering(w http.ResponseWriter, r *http.Request) {
-	htmlContent := `
-	<!DOCTYPE html>
-	<html>
-	<head>
-		<title>Go HTML Rendering</title>
-	</head>
-	<body>
-		<h1>Hello, this is a HTML document rendered with Go!</h1>
-	</body>
-	</html>
-	`
-	fmt.Fprint(w, htmlContent)
+	middleware.Serve(w, r, "html", serveHtmlRendering)
+}
+
+func serveHtmlRendering(w http.ResponseWriter, r *http.Request) {
+	render.HTML(w, r, http.StatusOK, "hello", nil)
 }
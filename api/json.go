@@ -2,12 +2,17 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
+
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/middleware"
 )
 
 func Json(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusCreated)
+	middleware.Serve(w, r, "json", serveJson)
+}
+
+func serveJson(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	resp := make(map[string]string)
 	resp["message"] = "Hello World from Go! 👋"
@@ -16,8 +21,10 @@ func Json(w http.ResponseWriter, r *http.Request) {
 	resp["github"] = "https://github.com/riccardogiorato/template-go-vercel/blob/main/api/json.go"
 	jsonResp, err := json.Marshal(resp)
 	if err != nil {
-		fmt.Printf("Error happened in JSON marshal. Err: %s", err)
+		logging.From(r.Context()).Error("JSON marshal failed", "error", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 	} else {
+		w.WriteHeader(http.StatusCreated)
 		w.Write(jsonResp)
 	}
 }
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/logo"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/ratelimit"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+)
+
+// Logo serves a channel's best available logo. vercel.json rewrites
+// /api/logo/{channelId} here. ?size=N pads and scales the logo to an N x N
+// PNG. Logos that are not PNG, JPEG or GIF images are refused with 415.
+func Logo(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "logo", serveLogo)
+}
+
+func serveLogo(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	id := q.Get("id")
+	if id == "" {
+		http.Error(w, "missing channel id", http.StatusBadRequest)
+		return
+	}
+	size := 0
+	if s := q.Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > logo.MaxSize {
+			http.Error(w, fmt.Sprintf("size must be between 1 and %d", logo.MaxSize), http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+	// Logos are public, and a cache miss loads the feed and decodes an
+	// image, so callers are limited by IP.
+	if !ratelimit.Check(w, r, "logo", "") {
+		return
+	}
+
+	f, err := fetch.Stream()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error configuring logo fetcher: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s := store.FromEnv()
+	proxy := &logo.Proxy{Fetcher: f, Store: s, TTL: logo.CacheTTL()}
+
+	// An overridden logo is cached under its own URL's hash, so changing
+	// the override does not serve the previous image.
+	cacheID, custom := id, ""
+	if set, err := override.Load(r.Context(), s); err != nil {
+		logging.From(r.Context()).Warn("loading channel overrides failed", "error", err)
+	} else if custom = set[id].Logo; custom != "" {
+		sum := sha256.Sum256([]byte(custom))
+		cacheID = id + "@" + hex.EncodeToString(sum[:4])
+	}
+
+	img, err := proxy.Cached(r.Context(), cacheID, size)
+	if err != nil {
+		if err != store.ErrNotFound {
+			logging.From(r.Context()).Warn("reading logo cache failed", "error", err)
+		}
+		if custom != "" {
+			img, err = proxy.Load(r.Context(), source.Channel{ID: cacheID, Logo: custom}, size)
+		} else {
+			img, err = loadLogo(r, proxy, id, size)
+		}
+		if err != nil {
+			logging.From(r.Context()).Warn("loading logo failed", "channel", id, "error", err)
+			http.Error(w, logo.Message(err), logo.Status(err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("Cache-Control", "public, max-age=86400, s-maxage=604800")
+	w.Write(img.Data)
+}
+
+func loadLogo(r *http.Request, proxy *logo.Proxy, id string, size int) (*logo.Image, error) {
+	src, err := source.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		logging.From(r.Context()).Error("loading channels from MEDIA_URL failed", "error", err)
+		return nil, errors.New("Error loading channels from MEDIA_URL: " + fetch.Message(err))
+	}
+	for _, ch := range channels {
+		if ch.ID == id {
+			return proxy.Load(r.Context(), ch, size)
+		}
+	}
+	return nil, fmt.Errorf("channel %s not found", id)
+}
@@ -1,255 +1,186 @@
 package handler
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
-	"net/url"
-	"os"
-	"strings"
-	"time"
+
+	"template-go-vercel/pkg/catchup"
+	"template-go-vercel/pkg/changes"
+	"template-go-vercel/pkg/health"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/logo"
+	"template-go-vercel/pkg/m3u"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/numbering"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/ratelimit"
+	"template-go-vercel/pkg/snapshot"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/vod"
+	"template-go-vercel/pkg/web"
 )
 
-type URL struct {
-	url.URL
-	Raw string
+func M3u(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "m3u", serveM3u)
 }
 
-func (u *URL) String() string {
-	return u.Raw
-}
-func (u *URL) Set(s string) (err error) {
-	parsed, err := url.Parse(s)
-	u.URL = *parsed
-	u.Raw = s
-	return
-}
+func serveM3u(w http.ResponseWriter, r *http.Request) {
+	src, err := source.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-type EXTINF struct {
-	Id        string `extinf:"tvg-id"`
-	Name      string `extinf:"tvg-name"`
-	Logo      string `extinf:"tvg-logo"`
-	Group     string `extinf:"group-title"`
-	Number    int    `extinf:"tvg-chno"`
-	Title     string
-	Url       string
-	SD        bool
-	HD        bool
-	FHD       bool
-	Prefix    string
-	NewName   string
-	MatchName string
-}
-type M3UData struct {
-	List []*EXTINF
-}
+	profile, tok, err := token.Authorize(r)
+	if err != nil {
+		http.Error(w, err.Error(), token.Status(err))
+		return
+	}
+	if !ratelimit.Check(w, r, "m3u", tok) {
+		return
+	}
 
-func (m3u *M3UData) M3UData() []byte {
-	var stringSlice []string
+	numberer, err := numbering.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	for _, inf := range m3u.List {
-		if inf == nil {
-			continue
-		}
-		/*suffix, prefix := "", ""
-		if inf.HD {
-			suffix = " HD"
-		}
-		if inf.FHD {
-			suffix = " FHD"
-		}
-		if inf.Prefix != "" {
-			prefix = inf.Prefix + ": "
-		}*/
-
-		//name := prefix + inf.NewName + suffix
-		name := inf.Title
-
-		//fmt.Printf("#EXTINF:-1 tvg-chno=\"%d\" tvg-id=\"%s\" tvg-name=\"%s\" tvg-logo=\"%s\" group-title=\"%s\", %s \n%s\n",
-		//    inf.Number, inf.Id, name, inf.Logo, inf.Group, name, inf.Url)
-		stringSlice = append(stringSlice, fmt.Sprintf(
-			"#EXTINF:-1 tvg-chno=\"%d\" tvg-id=\"%s\" tvg-name=\"%s\" tvg-logo=\"%s\" group-title=\"%s\", %s \n%s\n",
-			inf.Number,
-			inf.Id,
-			name,
-			inf.Logo,
-			inf.Group,
-			name,
-			inf.Url,
-		))
-	}
-	stringByte := strings.Join(stringSlice, "\x0a")
-	return []byte(stringByte)
-}
+	snapshots := store.FromEnv()
+	snapshotName := m3uSnapshot + ":" + profile.Name
+	if profile.Country != "" {
+		snapshotName += ":" + profile.Country
+	}
+	if tok != "" {
+		snapshotName += ":" + token.Key(tok)
+	}
+	// The playlist also varies with these options, and a fallback must not
+	// serve a variant the client did not ask for.
+	proxyLogos := logo.Enabled(r.URL.Query())
+	if proxyLogos {
+		snapshotName += ":logos"
+	}
+	healthy := r.URL.Query().Get("healthy")
+	switch healthy {
+	case "1", "true", "drop":
+		healthy = "drop"
+		snapshotName += ":healthy"
+	case "tag":
+		snapshotName += ":tagged"
+	}
 
-type Channel struct {
-	//Keywords         string        `json:"keywords"`
-	VodCategory      []interface{} `json:"vod_category"`
-	Categories       []interface{} `json:"categories"`
-	ID               string        `json:"_id"`
-	Title            string        `json:"title"`
-	SeriesID         string        `json:"series_id"`
-	AiredDate        int64         `json:"aired_date"`
-	AllowedCountries interface{}   `json:"allowedCountries"`
-	AdPolicyID       interface{}   `json:"adPolicyId"`
-	Epg              struct {
-		Events []struct {
-			Title  string    `json:"title"`
-			Start  time.Time `json:"start"`
-			End    time.Time `json:"end"`
-			Custom struct {
-				Duration int    `json:"duration"`
-				Rating   string `json:"rating"`
-				Image    struct {
-					Width       string `json:"width"`
-					Height      string `json:"height"`
-					DownloadURL string `json:"downloadUrl"`
-				} `json:"image"`
-			} `json:"custom"`
-		} `json:"events"`
-	} `json:"epg"`
-	Rating    string `json:"rating"`
-	MediaType string `json:"mediaType"`
-	Order     int    `json:"order"`
-	HLSStream struct {
-		DownloadURL  string `json:"downloadUrl"`
-		StreamingURL string `json:"streamingUrl"`
-		URL          string `json:"url"`
-	} `json:"HLSStream"`
-	CommerceType            string   `json:"commerceType,omitempty"`
-	PaidType                string   `json:"paidType,omitempty"`
-	SubscriptionsCategories []string `json:"subscriptionsCategories,omitempty"`
-	PosterH                 struct {
-		DownloadURL string `json:"downloadUrl"`
-	} `json:"PosterH"`
-	AndroidStream struct {
-		DownloadURL  string `json:"downloadUrl"`
-		StreamingURL string `json:"streamingUrl"`
-		URL          string `json:"url"`
-	} `json:"AndroidStream"`
-	AndroidBlockedStream struct {
-		DownloadURL  string `json:"downloadUrl"`
-		StreamingURL string `json:"streamingUrl"`
-		URL          string `json:"url"`
-	} `json:"AndroidBlockedStream"`
-	HLSBlockedStream struct {
-		DownloadURL  string `json:"downloadUrl"`
-		StreamingURL string `json:"streamingUrl"`
-		URL          string `json:"url"`
-	} `json:"HLSBlockedStream"`
-	LogoLarge        string `json:"logoLarge"`
-	ChannelLogoLarge struct {
-		DownloadURL  string `json:"downloadUrl"`
-		StreamingURL string `json:"streamingUrl"`
-		URL          string `json:"url"`
-	} `json:"ChannelLogoLarge"`
-	ChannelLogoTablets struct {
-		DownloadURL  string `json:"downloadUrl"`
-		StreamingURL string `json:"streamingUrl"`
-		URL          string `json:"url"`
-	} `json:"ChannelLogoTablets"`
-	PosterF struct {
-		DownloadURL string `json:"downloadUrl"`
-	} `json:"PosterF,omitempty"`
-}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		snapshot.ServeStale(w, r, snapshots, snapshotName, m3u.ContentType, "loading channels from MEDIA_URL", err)
+		return
+	}
 
-type Channels []Channel
+	channels, _ = vod.Split(channels)
+	changes.Track(r.Context(), channels)
 
-type response struct {
-	Title         string   `json:"title"`
-	ChannelImgURL string   `json:"channel_img_url"`
-	HLSStreamURL  string   `json:"hls_stream_url"`
-	Keywords      []string `json:"keywords"`
-}
+	// Numbers are assigned before filtering so every profile sees the same
+	// number for a channel.
+	if err := numberer.Assign(r.Context(), channels); err != nil {
+		logging.From(r.Context()).Warn("assigning channel numbers failed", "error", err)
+	}
+	channels = override.FromStore(r.Context(), snapshots, channels)
 
-func (u Channels) StreamList() []response {
-	var list []response
-	for _, channel := range u {
+	channels, decisions := policy.Apply(channels, profile)
 
-		list = append(list, response{
-			Title:         channel.Title,
-			ChannelImgURL: channel.ChannelLogoTablets.StreamingURL,
-			HLSStreamURL:  channel.AndroidStream.StreamingURL,
-			Keywords:      []string{}, //channel.Keywords,
-		})
+	if proxyLogos {
+		logo.Rewrite(channels, web.BaseURL(r), logo.DefaultSize())
 	}
-	return list
-}
 
-func (u Channels) StreamListToEXTINF(group string) []*EXTINF {
-	var list []*EXTINF
-	for inx, channel := range u {
-		list = append(list, &EXTINF{
-			Id:      channel.ID,
-			Name:    channel.Title,
-			NewName: channel.Title,
-			Logo:    channel.ChannelLogoTablets.DownloadURL,
-			Url:     channel.AndroidStream.StreamingURL,
-			Group:   group,
-			Number:  inx,
-			Title:   channel.Title,
-			FHD:     true,
-		})
+	extInfList := m3u.StreamListToEXTINF(channels, source.DefaultGroup)
+	if profile.Tier != policy.Unrestricted {
+		for _, inf := range extInfList {
+			d := decisions[inf.Id]
+			inf.Entitlement = string(d.Variant) + ": " + d.Reason
+		}
+	}
+	catchup.FromEnv().Apply(extInfList, channels, web.BaseURL(r), tok)
+
+	switch healthy {
+	case "drop":
+		extInfList = dropDeadChannels(r, extInfList)
+	case "tag":
+		extInfList = tagDeadChannels(r, extInfList)
+	}
 
+	popfd := &m3u.M3UData{List: signStreams(r, extInfList, tok)}
+	data := popfd.M3UData()
+	if err := snapshot.Save(r.Context(), snapshots, snapshotName, data); err != nil {
+		logging.From(r.Context()).Warn("saving playlist snapshot failed", "error", err)
 	}
-	return list
+
+	w.Header().Set("Content-Type", m3u.ContentType)
+	w.Write(data)
 }
 
-func getJSON(url string) ([]byte, error) {
-	client := &http.Client{}
-	fmt.Println("Attempting to fetch for url: ", url)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		fmt.Print(err.Error())
-		return nil, err
+// m3uSnapshot prefixes the names of the last-known-good playlists, which
+// are kept per policy profile, caller country, token and playlist options.
+const m3uSnapshot = "m3u"
+
+// signStreams returns list with every stream pointing at the signed
+// /api/stream redirect for tok. Without a token list is returned as is.
+func signStreams(r *http.Request, list []*m3u.EXTINF, tok string) []*m3u.EXTINF {
+	if tok == "" {
+		return list
 	}
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Print(err.Error())
-		return nil, err
+	base := web.BaseURL(r)
+	signed := make([]*m3u.EXTINF, len(list))
+	for i, inf := range list {
+		c := *inf
+		c.Url = token.StreamURL(base, inf.Id, tok)
+		signed[i] = &c
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := io.ReadAll(resp.Body)
+	return signed
+}
+
+// offlineGroup is the group-title given to dead channels with ?healthy=tag.
+const offlineGroup = "Offline"
+
+// probeChannels probes the streams of list, reusing the results cached by
+// earlier playlist requests.
+func probeChannels(r *http.Request, list []*m3u.EXTINF) ([]health.Result, error) {
+	checker, err := health.NewChecker()
 	if err != nil {
-		fmt.Print(err.Error())
 		return nil, err
 	}
-
-	return bodyBytes, nil
+	targets := make([]health.Target, len(list))
+	for i, inf := range list {
+		targets[i] = health.Target{ID: inf.Id, Name: inf.Title, URL: inf.Url}
+	}
+	return health.NewCache(store.FromEnv()).CheckAll(r.Context(), checker, targets), nil
 }
 
-func jsonToChannels(bytes []byte) (Channels, error) {
-	var response Channels
-	err := json.Unmarshal(
-		bytes,
-		&response,
-	)
+func dropDeadChannels(r *http.Request, list []*m3u.EXTINF) []*m3u.EXTINF {
+	results, err := probeChannels(r, list)
 	if err != nil {
-		return nil, err
+		logging.From(r.Context()).Warn("probing streams failed", "error", err)
+		return list
+	}
+	var alive []*m3u.EXTINF
+	for i, inf := range list {
+		if results[i].Up() {
+			alive = append(alive, inf)
+		}
 	}
-	return response, nil
+	return alive
 }
 
-func M3u(w http.ResponseWriter, r *http.Request) {
-
-	
-	fmt.Print(r.Header.Get("User-Agent"))
-	req, _ := getJSON(os.Getenv("MEDIA_URL"))
-
-	channels, err := jsonToChannels(req)
-	//channels
+func tagDeadChannels(r *http.Request, list []*m3u.EXTINF) []*m3u.EXTINF {
+	results, err := probeChannels(r, list)
 	if err != nil {
-		fmt.Fprintf(w, "Error")
-		fmt.Println("Error parsing JSON: ", err)
-		return
+		logging.From(r.Context()).Warn("probing streams failed", "error", err)
+		return list
 	}
-
-	extInfList := channels.StreamListToEXTINF("TVJ")
-
-	popfd := &M3UData{extInfList}
-
-	w.Write(popfd.M3UData())
+	for i, inf := range list {
+		if !results[i].Up() {
+			inf.Group = offlineGroup
+		}
+	}
+	return list
 }
@@ -6,6 +6,11 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"time"
+
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/ratelimit"
 )
 
 type User struct {
@@ -14,33 +19,58 @@ type User struct {
 	Email string `json:"email"`
 }
 
+// weatherClient bounds each lookup on top of the request's own deadline.
+var weatherClient = &http.Client{Timeout: 10 * time.Second}
+
 func MyWeather(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
+	middleware.Serve(w, r, "myweather", serveMyWeather)
+}
+
+func serveMyWeather(w http.ResponseWriter, r *http.Request) {
+	if !ratelimit.Check(w, r, "myweather", "") {
+		return
+	}
 	resp := make(map[string]string)
 
 	// IP User
 	userIpAddress := "93.34.228.207" // r.RemoteAddr
 	// Response API Location
 	urlLocationApi := "https://api.freegeoip.app/json/" + userIpAddress + "?apikey=" + os.Getenv("API_KEY_FREEGEOIP")
-	fmt.Println("Location API URL:", urlLocationApi)
-	userLocationResponse, _ := http.Get(urlLocationApi)
+	userLocationResponse, err := getWeatherAPI(r, urlLocationApi)
+	if err != nil {
+		// err names the URL, which carries the API key.
+		logging.From(r.Context()).Error("location lookup failed", "error", err)
+		http.Error(w, "Error looking up location", http.StatusBadGateway)
+		return
+	}
+	defer userLocationResponse.Body.Close()
 	// Get the Location body
 	userLocationBody, _ := io.ReadAll(userLocationResponse.Body)
 	userLocationJson := string(userLocationBody)
-	fmt.Println("Location API Json String:", userLocationJson)
+	logging.From(r.Context()).Debug("location lookup result", "body", userLocationJson)
 	var userLocation map[string]interface{}
 	json.Unmarshal([]byte(userLocationBody), &userLocation)
 
-	resp["latitude"] = fmt.Sprint(userLocation["latitude"].(float64))
-	resp["longitude"] = fmt.Sprint(userLocation["longitude"].(float64))
+	latitude, okLat := userLocation["latitude"].(float64)
+	longitude, okLon := userLocation["longitude"].(float64)
+	if !okLat || !okLon {
+		http.Error(w, "Error looking up location: no coordinates in response", http.StatusBadGateway)
+		return
+	}
+	resp["latitude"] = fmt.Sprint(latitude)
+	resp["longitude"] = fmt.Sprint(longitude)
 
 	resp["github"] = "https://github.com/riccardogiorato/template-go-vercel/blob/main/api/myweather.go"
 
 	// Response API Weather
 	urlWeatherApi := "https://api.openweathermap.org/data/2.5/weather?lat=" + resp["latitude"] + "&lon=" + resp["longitude"] + "&appid=" + os.Getenv("API_KEY_OPENWEATHER")
-	fmt.Println("Weather API Json String:", urlWeatherApi)
-	weatherApiResponse, _ := http.Get(urlWeatherApi)
+	weatherApiResponse, err := getWeatherAPI(r, urlWeatherApi)
+	if err != nil {
+		logging.From(r.Context()).Error("weather lookup failed", "error", err)
+		http.Error(w, "Error looking up weather", http.StatusBadGateway)
+		return
+	}
+	defer weatherApiResponse.Body.Close()
 	weatherApiBody, _ := io.ReadAll(weatherApiResponse.Body)
 	weatherApiJson := string(weatherApiBody)
 
@@ -48,8 +78,20 @@ func MyWeather(w http.ResponseWriter, r *http.Request) {
 
 	jsonResp, err := json.Marshal(resp)
 	if err != nil {
-		fmt.Println("Error happened in JSON marshal. Err:", err)
-	} else {
-		w.Write(jsonResp)
+		logging.From(r.Context()).Error("JSON marshal failed", "error", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonResp)
+}
+
+// getWeatherAPI GETs url with weatherClient, cancelled with the request.
+func getWeatherAPI(r *http.Request, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
 	}
+	return weatherClient.Do(req)
 }
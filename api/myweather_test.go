@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestMyWeatherHidesAPIKey(t *testing.T) {
+	t.Setenv("REDIS_URL", "")
+	t.Setenv("API_KEY_FREEGEOIP", "geo-secret")
+	orig := weatherClient
+	weatherClient = &http.Client{Transport: failingTransport{}}
+	defer func() { weatherClient = orig }()
+
+	w := httptest.NewRecorder()
+	MyWeather(w, httptest.NewRequest(http.MethodGet, "/api/myweather", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("got %d, want 502", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "geo-secret") {
+		t.Errorf("response leaks the API key: %s", w.Body)
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/store"
+)
+
+// Overrides manages channel overrides. vercel.json rewrites
+// /api/overrides/{channelId} here. It requires ADMIN_TOKEN.
+//
+//	GET    /api/overrides         every override, keyed by channel id
+//	GET    /api/overrides/{id}    one override
+//	PUT    /api/overrides/{id}    replace a channel's override with the JSON body
+//	DELETE /api/overrides/{id}    remove a channel's override
+func Overrides(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "overrides", serveOverrides, http.MethodGet, http.MethodPut, http.MethodDelete)
+}
+
+func serveOverrides(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireAdmin(w, r) {
+		return
+	}
+
+	s := store.FromEnv()
+	if r.Method != http.MethodGet && !store.Durable(s) {
+		http.Error(w, store.ErrNotDurable.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	switch {
+	case r.Method == http.MethodGet:
+		set, err := override.Load(r.Context(), s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if id == "" {
+			writeOverrideJSON(w, http.StatusOK, set)
+			return
+		}
+		o, ok := set[id]
+		if !ok {
+			http.Error(w, "no override for channel "+id, http.StatusNotFound)
+			return
+		}
+		writeOverrideJSON(w, http.StatusOK, o)
+	case id == "":
+		http.Error(w, "missing channel id", http.StatusBadRequest)
+	case r.Method == http.MethodPut:
+		var o override.Override
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&o); err != nil {
+			http.Error(w, fmt.Sprintf("Error decoding override: %v", err), http.StatusBadRequest)
+			return
+		}
+		status := http.StatusOK
+		_, err := override.Update(r.Context(), s, func(set override.Set) error {
+			status = http.StatusOK
+			if _, ok := set[id]; !ok {
+				status = http.StatusCreated
+			}
+			if o.IsZero() {
+				delete(set, id)
+			} else {
+				set[id] = o
+			}
+			return nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeOverrideJSON(w, status, o)
+	case r.Method == http.MethodDelete:
+		_, err := override.Update(r.Context(), s, func(set override.Set) error {
+			if _, ok := set[id]; !ok {
+				return errNoOverride
+			}
+			delete(set, id)
+			return nil
+		})
+		switch err {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errNoOverride:
+			http.Error(w, "no override for channel "+id, http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// errNoOverride stops an update that would delete a missing override.
+var errNoOverride = errors.New("no override")
+
+func writeOverrideJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error happened in JSON marshal: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOverrides(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	t.Setenv("REDIS_URL", "")
+	t.Setenv("STORE_DIR", t.TempDir())
+
+	do := func(method, id, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/overrides?id="+id, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		Overrides(w, r)
+		return w
+	}
+
+	if w := do(http.MethodPut, "tvj", `{"name":"TVJ HD"}`); w.Code != http.StatusCreated {
+		t.Fatalf("PUT new: got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPut, "tvj", `{"name":"TVJ"}`); w.Code != http.StatusOK {
+		t.Errorf("PUT existing: got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPut, "cvm", `{"hidden":true}`); w.Code != http.StatusCreated {
+		t.Errorf("PUT second channel: got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodGet, "", ""); !strings.Contains(w.Body.String(), `"tvj":{"name":"TVJ"}`) || !strings.Contains(w.Body.String(), `"cvm":{"hidden":true}`) {
+		t.Errorf("GET: got %s", w.Body)
+	}
+	if w := do(http.MethodDelete, "tvj", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE: got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodDelete, "tvj", ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE again: got %d, want 404", w.Code)
+	}
+	if w := do(http.MethodGet, "cvm", ""); w.Code != http.StatusOK {
+		t.Errorf("GET cvm after deleting tvj: got %d", w.Code)
+	}
+
+	t.Setenv("STORE_DIR", "")
+	if w := do(http.MethodPut, "tvj", `{"name":"TVJ"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("PUT without a durable store: got %d, want 503", w.Code)
+	}
+}
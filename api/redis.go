@@ -1,45 +1,225 @@
 package handler
 
 import (
-	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/rdb"
 )
 
-var ctx = context.Background()
+// kvPrefix namespaces the keys of the KV API inside Redis.
+const kvPrefix = "kv:"
+
+// kvMaxValue is the largest value accepted by PUT.
+const kvMaxValue = 1 << 20
+
+// kvClient returns the Redis client; tests point it at a fake server.
+var kvClient = rdb.Client
 
-var client = redis.NewClient(&redis.Options{
-	Addr:     "global-many-spaniel-31036.upstash.io:31036",
-	Password: os.Getenv("UPSTASH_PASSWORD"),
-	DB:       0,
-	TLSConfig: &tls.Config{
-		MinVersion: tls.VersionTLS12,
-	},
-})
+type kvList struct {
+	Keys   []string `json:"keys"`
+	Cursor string   `json:"cursor"`
+}
 
+// Redis is a small key-value REST API. vercel.json rewrites /api/kv/{key}
+// here. Every request needs KV_TOKEN or ADMIN_TOKEN.
+//
+//	GET    /api/kv/{key}                     the stored value with its content type
+//	PUT    /api/kv/{key}?ttl=1h              store the request body, optionally expiring
+//	DELETE /api/kv/{key}                     remove the key
+//	GET    /api/kv?prefix=&cursor=&count=    list keys, one SCAN page at a time
 func Redis(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
+	middleware.Serve(w, r, "kv", serveRedis, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete)
+}
+
+func serveRedis(w http.ResponseWriter, r *http.Request) {
+	if !auth.Token(r, "KV_TOKEN") && !auth.Admin(r) {
+		auth.Unauthorized(w)
+		return
+	}
+
+	client, err := kvClient()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	key := strings.Trim(r.URL.Query().Get("key"), "/")
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		kvScan(w, r, client)
+	case key == "":
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		kvGet(w, r, client, key)
+	case r.Method == http.MethodPut:
+		kvPut(w, r, client, key)
+	case r.Method == http.MethodDelete:
+		kvDelete(w, r, client, key)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-	// set a foo key on upstash with this value
-	client.Set(ctx, "foo", "value-from-upstash-redis", 0)
+func kvGet(w http.ResponseWriter, r *http.Request, client *redis.Client, key string) {
+	pipe := client.Pipeline()
+	get := pipe.HMGet(r.Context(), kvPrefix+key, "value", "type")
+	ttl := pipe.PTTL(r.Context(), kvPrefix+key)
+	if _, err := pipe.Exec(r.Context()); err != nil {
+		http.Error(w, fmt.Sprintf("Error reading key: %v", err), http.StatusBadGateway)
+		return
+	}
+	fields := get.Val()
+	value, ok := fields[0].(string)
+	if !ok {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	contentType, _ := fields[1].(string)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
 
-	// get the foo key from upstash
-	foo := client.Get(ctx, "foo")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if d := ttl.Val(); d > 0 {
+		w.Header().Set("X-KV-Expires-At", time.Now().Add(d).UTC().Format(time.RFC3339))
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(value)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.WriteString(w, value)
+}
 
-	resp := make(map[string]string)
-	resp["foo"] = foo.Val()
-	resp["github"] = "https://github.com/riccardogiorato/template-go-vercel/blob/main/api/redis.go"
-	body, err := json.Marshal(resp)
+func kvPut(w http.ResponseWriter, r *http.Request, client *redis.Client, key string) {
+	var ttl time.Duration
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			secs, serr := strconv.Atoi(v)
+			if serr != nil {
+				http.Error(w, "ttl must be a duration such as 1h or a number of seconds", http.StatusBadRequest)
+				return
+			}
+			d = time.Duration(secs) * time.Second
+		}
+		if d <= 0 {
+			http.Error(w, "ttl must be positive", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
 
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, kvMaxValue))
 	if err != nil {
-		fmt.Printf("Error happened in JSON marshal. Err: %s", err)
+		http.Error(w, fmt.Sprintf("Error reading body: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if mt, _, _ := mime.ParseMediaType(contentType); mt == "application/json" && !json.Valid(body) {
+		http.Error(w, "body is not valid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	pipe := client.TxPipeline()
+	exists := pipe.Exists(ctx, kvPrefix+key)
+	pipe.HSet(ctx, kvPrefix+key, "value", body, "type", contentType)
+	if ttl > 0 {
+		pipe.PExpire(ctx, kvPrefix+key, ttl)
 	} else {
-		w.Write(body)
+		pipe.Persist(ctx, kvPrefix+key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		http.Error(w, fmt.Sprintf("Error writing key: %v", err), http.StatusBadGateway)
+		return
+	}
+	if exists.Val() == 0 {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func kvDelete(w http.ResponseWriter, r *http.Request, client *redis.Client, key string) {
+	n, err := client.Del(r.Context(), kvPrefix+key).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting key: %v", err), http.StatusBadGateway)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func kvScan(w http.ResponseWriter, r *http.Request, client *redis.Client) {
+	q := r.URL.Query()
+	var cursor uint64
+	if v := q.Get("cursor"); v != "" {
+		c, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		cursor = c
+	}
+	count := int64(100)
+	if v := q.Get("count"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "count must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	match := kvPrefix + globEscape(q.Get("prefix")) + "*"
+	keys, next, err := client.Scan(r.Context(), cursor, match, count).Result()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listing keys: %v", err), http.StatusBadGateway)
+		return
+	}
+	list := kvList{Keys: make([]string, len(keys)), Cursor: strconv.FormatUint(next, 10)}
+	for i, k := range keys {
+		list.Keys[i] = strings.TrimPrefix(k, kvPrefix)
+	}
+
+	body, err := json.Marshal(list)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error happened in JSON marshal: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// globEscape escapes the characters SCAN MATCH treats as patterns.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
 	}
+	return b.String()
 }
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// kvServer points the KV API at a fresh miniredis and returns a function
+// making requests with KV_TOKEN.
+func kvServer(t *testing.T) (*miniredis.Miniredis, func(method, target, contentType, body string) *httptest.ResponseRecorder) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	orig := kvClient
+	kvClient = func() (*redis.Client, error) { return client, nil }
+	t.Cleanup(func() { kvClient = orig })
+	t.Setenv("KV_TOKEN", "kv-secret")
+	t.Setenv("ADMIN_TOKEN", "")
+
+	return mr, func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer kv-secret")
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		Redis(w, r)
+		return w
+	}
+}
+
+func TestKVAuth(t *testing.T) {
+	kvServer(t)
+	for _, header := range []string{"", "Bearer wrong"} {
+		r := httptest.NewRequest(http.MethodGet, "/api/redis?key=a", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		Redis(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: got %d, want 401", header, w.Code)
+		}
+	}
+}
+
+func TestKVPutGetDelete(t *testing.T) {
+	_, do := kvServer(t)
+
+	if w := do(http.MethodPut, "/api/redis?key=greeting", "text/plain", "hello"); w.Code != http.StatusCreated {
+		t.Fatalf("PUT new key: got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPut, "/api/redis?key=greeting", "text/plain", "hi"); w.Code != http.StatusNoContent {
+		t.Errorf("PUT existing key: got %d, want 204", w.Code)
+	}
+	w := do(http.MethodGet, "/api/redis?key=greeting", "", "")
+	if w.Code != http.StatusOK || w.Body.String() != "hi" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("GET: got %d %q as %q", w.Code, w.Body, w.Header().Get("Content-Type"))
+	}
+	w = do(http.MethodHead, "/api/redis?key=greeting", "", "")
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "2" {
+		t.Errorf("HEAD: got %d with %d bytes and Content-Length %q", w.Code, w.Body.Len(), w.Header().Get("Content-Length"))
+	}
+
+	if w := do(http.MethodDelete, "/api/redis?key=greeting", "", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE: got %d, want 204", w.Code)
+	}
+	if w := do(http.MethodGet, "/api/redis?key=greeting", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET deleted key: got %d, want 404", w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/redis?key=greeting", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE missing key: got %d, want 404", w.Code)
+	}
+	if w := do(http.MethodPut, "/api/redis", "text/plain", "x"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT without a key: got %d, want 405", w.Code)
+	}
+}
+
+func TestKVJSON(t *testing.T) {
+	_, do := kvServer(t)
+	if w := do(http.MethodPut, "/api/redis?key=doc", "application/json", `{"a":`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid JSON: got %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPut, "/api/redis?key=doc", "application/json; charset=utf-8", `{"a":1}`); w.Code != http.StatusCreated {
+		t.Errorf("valid JSON: got %d: %s", w.Code, w.Body)
+	}
+	// Other types are stored as they are.
+	if w := do(http.MethodPut, "/api/redis?key=raw", "text/plain", `{"a":`); w.Code != http.StatusCreated {
+		t.Errorf("text that is not JSON: got %d", w.Code)
+	}
+}
+
+func TestKVTTL(t *testing.T) {
+	mr, do := kvServer(t)
+
+	for _, ttl := range []string{"abc", "-5s", "0"} {
+		if w := do(http.MethodPut, "/api/redis?key=k&ttl="+ttl, "text/plain", "v"); w.Code != http.StatusBadRequest {
+			t.Errorf("ttl=%s: got %d, want 400", ttl, w.Code)
+		}
+	}
+
+	for ttl, want := range map[string]time.Duration{"90": 90 * time.Second, "1h": time.Hour} {
+		key := "ttl-" + ttl
+		if w := do(http.MethodPut, "/api/redis?key="+key+"&ttl="+ttl, "text/plain", "v"); w.Code != http.StatusCreated {
+			t.Fatalf("ttl=%s: got %d: %s", ttl, w.Code, w.Body)
+		}
+		if got := mr.TTL(kvPrefix + key); got != want {
+			t.Errorf("ttl=%s: key expires in %s, want %s", ttl, got, want)
+		}
+		w := do(http.MethodGet, "/api/redis?key="+key, "", "")
+		exp, err := time.Parse(time.RFC3339, w.Header().Get("X-KV-Expires-At"))
+		if err != nil || exp.Sub(time.Now()) > want+time.Second || exp.Sub(time.Now()) < want-2*time.Second {
+			t.Errorf("ttl=%s: got X-KV-Expires-At %q", ttl, w.Header().Get("X-KV-Expires-At"))
+		}
+	}
+
+	// Writing without a ttl makes the key permanent again.
+	do(http.MethodPut, "/api/redis?key=ttl-90", "text/plain", "v2")
+	if w := do(http.MethodGet, "/api/redis?key=ttl-90", "", ""); w.Header().Get("X-KV-Expires-At") != "" {
+		t.Errorf("after a PUT without ttl: got X-KV-Expires-At %q", w.Header().Get("X-KV-Expires-At"))
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if w := do(http.MethodGet, "/api/redis?key=ttl-1h", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expired key: got %d, want 404", w.Code)
+	}
+}
+
+func TestKVScan(t *testing.T) {
+	mr, do := kvServer(t)
+	var want []string
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("user:%02d", i)
+		mr.HSet(kvPrefix+key, "value", "v")
+		want = append(want, key)
+	}
+	mr.HSet(kvPrefix+"user*x", "value", "v")
+	mr.HSet(kvPrefix+"other", "value", "v")
+	mr.Set("not-kv", "v")
+
+	list := func(query string) kvList {
+		t.Helper()
+		w := do(http.MethodGet, "/api/redis?"+query, "", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", query, w.Code, w.Body)
+		}
+		var l kvList
+		if err := json.Unmarshal(w.Body.Bytes(), &l); err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+
+	var got []string
+	cursor, pages := "0", 0
+	for {
+		l := list("prefix=user:&count=10&cursor=" + cursor)
+		got = append(got, l.Keys...)
+		pages++
+		if cursor = l.Cursor; cursor == "0" || pages > 10 {
+			break
+		}
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("paged SCAN of user: got %q", got)
+	}
+	if pages < 2 {
+		t.Errorf("got %d page, want SCAN to page with count=10", pages)
+	}
+
+	// Glob characters in the prefix are matched literally.
+	if l := list("prefix=user*"); len(l.Keys) != 1 || l.Keys[0] != "user*x" {
+		t.Errorf("prefix user*: got %q, want only user*x", l.Keys)
+	}
+
+	for _, bad := range []string{"cursor=x", "count=0", "count=1001"} {
+		if w := do(http.MethodGet, "/api/redis?"+bad, "", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", bad, w.Code)
+		}
+	}
+}
+
+func TestGlobEscape(t *testing.T) {
+	for in, want := range map[string]string{
+		"user:":   "user:",
+		"a*b?c":   `a\*b\?c`,
+		"[x]":     `\[x\]`,
+		`back\sl`: `back\\sl`,
+		"café":    "café",
+	} {
+		if got := globEscape(in); got != want {
+			t.Errorf("globEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/reminder"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/webhook"
+)
+
+// maxRemindersPerOwner caps how many reminders one token holder may keep.
+const maxRemindersPerOwner = 50
+
+type reminderRequest struct {
+	Title     string     `json:"title"`
+	ChannelID string     `json:"channel_id"`
+	Start     *time.Time `json:"start"`
+	Lead      *int       `json:"lead_minutes"`
+	Webhook   string     `json:"webhook_url"`
+}
+
+// Reminders manages programme reminders. Admins (ADMIN_TOKEN) see and
+// manage every reminder; holders of a playlist token (?token=) manage their
+// own. Reminders can only be registered once WEBHOOK_SECRET is set, and
+// their webhooks must point at public hosts.
+//
+//	GET    /api/reminders          list reminders
+//	POST   /api/reminders          register {"title","channel_id","start","lead_minutes","webhook_url"}
+//	DELETE /api/reminders?id=ID    remove a reminder
+func Reminders(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "reminders", serveReminders, http.MethodGet, http.MethodPost, http.MethodDelete)
+}
+
+func serveReminders(w http.ResponseWriter, r *http.Request) {
+	s := store.FromEnv()
+	owner, ok := reminderOwner(r, s)
+	if !ok {
+		auth.Unauthorized(w)
+		return
+	}
+
+	if r.Method != http.MethodGet && !store.Durable(s) {
+		http.Error(w, store.ErrNotDurable.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	mine := func(rem *reminder.Reminder) bool {
+		return owner == "admin" || rem.Owner == owner
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		set, err := reminder.Load(r.Context(), s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		list := []*reminder.Reminder{}
+		for _, rem := range set {
+			if mine(rem) {
+				list = append(list, rem)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+		writeReminderJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		if !webhook.Enabled() {
+			http.Error(w, "WEBHOOK_SECRET environment variable is not set", http.StatusNotImplemented)
+			return
+		}
+		var req reminderRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Error decoding reminder: %v", err), http.StatusBadRequest)
+			return
+		}
+		rem := &reminder.Reminder{
+			ID:        reminder.NewID(),
+			Owner:     owner,
+			Title:     req.Title,
+			ChannelID: req.ChannelID,
+			Start:     req.Start,
+			Lead:      reminder.DefaultLead(),
+			Webhook:   req.Webhook,
+			CreatedAt: time.Now().UTC(),
+		}
+		if req.Lead != nil {
+			rem.Lead = *req.Lead
+		}
+		if err := rem.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err := reminder.Update(r.Context(), s, func(set reminder.Set) error {
+			count := 0
+			for _, other := range set {
+				if other.Owner == owner {
+					count++
+				}
+			}
+			if owner != "admin" && count >= maxRemindersPerOwner {
+				return errTooManyReminders
+			}
+			set[rem.ID] = rem
+			return nil
+		})
+		switch err {
+		case nil:
+			writeReminderJSON(w, http.StatusCreated, rem)
+		case errTooManyReminders:
+			http.Error(w, fmt.Sprintf("at most %d reminders are allowed", maxRemindersPerOwner), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		err := reminder.Update(r.Context(), s, func(set reminder.Set) error {
+			rem, ok := set[id]
+			if !ok || !mine(rem) {
+				return reminder.ErrNotFound
+			}
+			delete(set, id)
+			return nil
+		})
+		switch err {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case reminder.ErrNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// reminderOwner identifies the caller: "admin" for ADMIN_TOKEN, otherwise
+// the subject of a valid playlist token.
+func reminderOwner(r *http.Request, s store.Store) (string, bool) {
+	if auth.Admin(r) {
+		return "admin", true
+	}
+	tok := r.URL.Query().Get("token")
+	if !token.Enabled() || tok == "" {
+		return "", false
+	}
+	claims, err := token.Verify(r.Context(), s, tok)
+	if err != nil {
+		return "", false
+	}
+	return "token:" + claims.Subject, true
+}
+
+// errTooManyReminders stops an update that would exceed
+// maxRemindersPerOwner.
+var errTooManyReminders = errors.New("too many reminders")
+
+func writeReminderJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error happened in JSON marshal: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
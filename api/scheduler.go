@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/changes"
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/reminder"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/vod"
+	"template-go-vercel/pkg/webhook"
+)
+
+type schedulerReport struct {
+	RanAt      time.Time           `json:"ran_at"`
+	Deliveries []reminder.Delivery `json:"deliveries"`
+}
+
+// Scheduler records lineup changes and sends the reminders that are due.
+// It is called by the Vercel cron job in vercel.json, which authenticates
+// with CRON_SECRET, and may also be called with ADMIN_TOKEN.
+func Scheduler(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "scheduler", serveScheduler, http.MethodGet, http.MethodHead, http.MethodPost)
+}
+
+func serveScheduler(w http.ResponseWriter, r *http.Request) {
+	if !auth.Token(r, "CRON_SECRET") && !auth.Admin(r) {
+		auth.Unauthorized(w)
+		return
+	}
+
+	channels, err := reminderChannels(r)
+	if err != nil {
+		fetch.Fail(w, r, "loading channels from MEDIA_URL", err)
+		return
+	}
+
+	sched := &reminder.Scheduler{Store: store.FromEnv(), Sender: webhook.NewPublic()}
+	report := schedulerReport{RanAt: time.Now().UTC()}
+	report.Deliveries, err = sched.Run(r.Context(), channels)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for _, d := range report.Deliveries {
+		if d.Error != "" {
+			logging.From(r.Context()).Warn("sending reminder failed", "reminder_id", d.ReminderID, "error", d.Error)
+		}
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error happened in JSON marshal: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// reminderChannels loads the live channels with their overrides, so
+// notifications use the names viewers see. Lineup changes are recorded
+// here too, so they are found while nobody pulls a playlist.
+func reminderChannels(r *http.Request) ([]source.Channel, error) {
+	src, err := source.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	channels, _ = vod.Split(channels)
+	changes.Track(r.Context(), channels)
+	return override.FromStore(r.Context(), store.FromEnv(), channels), nil
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+
+	"template-go-vercel/pkg/catchup"
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/ratelimit"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/web"
+)
+
+// Stream redirects a signed playlist entry to the channel's stream.
+// vercel.json rewrites /api/stream/{channelId} here; the caller's token
+// decides which stream variant they get. Requests carrying a catch-up
+// window, added by players in append or shift mode, are sent on to
+// /api/catchup.
+func Stream(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "stream", serveStream)
+}
+
+func serveStream(w http.ResponseWriter, r *http.Request) {
+	profile, tok, err := token.Authorize(r)
+	if err != nil {
+		http.Error(w, err.Error(), token.Status(err))
+		return
+	}
+	if !ratelimit.Check(w, r, "stream", tok) {
+		return
+	}
+
+	q := r.URL.Query()
+	if start, end, ok := catchup.Window(q); ok {
+		query := url.Values{"start": {start}, "end": {end}}.Encode()
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, catchup.ResolverURL(web.BaseURL(r), q.Get("id"), query, tok), http.StatusFound)
+		return
+	}
+
+	src, err := source.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		fetch.Fail(w, r, "loading channels from MEDIA_URL", err)
+		return
+	}
+
+	channels = override.FromStore(r.Context(), store.FromEnv(), channels)
+
+	id := q.Get("id")
+	for _, ch := range channels {
+		if ch.ID != id {
+			continue
+		}
+		d := policy.Decide(ch, profile)
+		target := ch.StreamURL
+		switch {
+		case !d.Include:
+			http.Error(w, d.Reason, http.StatusForbidden)
+			return
+		case d.Variant == policy.Blocked:
+			target = ch.BlockedStreamURL
+		}
+		if target == "" {
+			break
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, target, http.StatusFound)
+		return
+	}
+	http.NotFound(w, r)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/token"
+)
+
+// serveSigned requests a token.StreamURL the way vercel.json rewrites
+// /api/stream/{channelId} to /api/stream?id={channelId}.
+func serveSigned(t *testing.T, streamURL string) *httptest.ResponseRecorder {
+	t.Helper()
+	u, err := url.Parse(streamURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := url.PathUnescape(strings.TrimPrefix(u.EscapedPath(), "/api/stream/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("id", id)
+	w := httptest.NewRecorder()
+	Stream(w, httptest.NewRequest(http.MethodGet, "http://iptv.example.com/api/stream?"+q.Encode(), nil))
+	return w
+}
+
+func TestStreamSignedURL(t *testing.T) {
+	xtreamEnv(t)
+	t.Setenv("PLAYLIST_SECRET", "secret")
+	tok, _, err := token.Issue("alice", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serveSigned(t, token.StreamURL("http://iptv.example.com", "tvj", tok))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://cdn.example.com/tvj.m3u8" {
+		t.Errorf("signed URL: got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := serveSigned(t, token.StreamURL("http://iptv.example.com", "nope", tok)); w.Code != http.StatusNotFound {
+		t.Errorf("unknown channel: got %d, want 404", w.Code)
+	}
+	if w := serveSigned(t, token.StreamURL("http://iptv.example.com", "tvj", tok+"x")); w.Code != http.StatusUnauthorized {
+		t.Errorf("tampered token: got %d, want 401", w.Code)
+	}
+
+	t.Setenv("PLAYLIST_SECRET", "rotated")
+	if w := serveSigned(t, token.StreamURL("http://iptv.example.com", "tvj", tok)); w.Code != http.StatusUnauthorized {
+		t.Errorf("token of the old secret: got %d, want 401", w.Code)
+	}
+}
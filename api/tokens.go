@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/web"
+)
+
+type issueRequest struct {
+	Subject string `json:"subject"`
+	Profile string `json:"profile"`
+	// TTL is a Go duration such as "720h"; empty means no expiry.
+	TTL string `json:"ttl"`
+}
+
+type issueResponse struct {
+	Token       string     `json:"token"`
+	ID          string     `json:"id"`
+	Subject     string     `json:"subject"`
+	Profile     string     `json:"profile,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	PlaylistURL string     `json:"playlist_url"`
+	GuideURL    string     `json:"guide_url"`
+}
+
+// Tokens manages playlist tokens. It requires ADMIN_TOKEN.
+//
+//	POST   /api/tokens           issue a token from {"subject","profile","ttl"}
+//	GET    /api/tokens?id=ID     report whether a token is revoked
+//	DELETE /api/tokens?id=ID     revoke a token
+func Tokens(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "tokens", serveTokens, http.MethodGet, http.MethodPost, http.MethodDelete)
+}
+
+func serveTokens(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireAdmin(w, r) {
+		return
+	}
+	if !token.Enabled() {
+		http.Error(w, "PLAYLIST_SECRET environment variable is not set", http.StatusNotImplemented)
+		return
+	}
+	s := store.FromEnv()
+	id := r.URL.Query().Get("id")
+
+	switch r.Method {
+	case http.MethodPost:
+		var req issueRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Subject == "" {
+			http.Error(w, "body must be JSON with a subject", http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			d, err := time.ParseDuration(req.TTL)
+			if err != nil || d <= 0 {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+			ttl = d
+		}
+		if req.Profile != "" {
+			if _, err := policy.Lookup(req.Profile); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		tok, claims, err := token.Issue(req.Subject, req.Profile, ttl)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		base := web.BaseURL(r) + "/t/" + url.PathEscape(tok)
+		resp := issueResponse{
+			Token:       tok,
+			ID:          claims.ID,
+			Subject:     claims.Subject,
+			Profile:     claims.Profile,
+			PlaylistURL: base + "/M3U",
+			GuideURL:    base + "/xmltv.xml",
+		}
+		if claims.ExpiresAt != 0 {
+			exp := time.Unix(claims.ExpiresAt, 0).UTC()
+			resp.ExpiresAt = &exp
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	case http.MethodGet:
+		if id == "" {
+			http.Error(w, "missing id", http.StatusBadRequest)
+			return
+		}
+		revoked, err := token.Revoked(r.Context(), s, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%q,"revoked":%t}`, id, revoked)
+	case http.MethodDelete:
+		if id == "" {
+			http.Error(w, "missing id", http.StatusBadRequest)
+			return
+		}
+		if !store.Durable(s) {
+			http.Error(w, store.ErrNotDurable.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err := token.Revoke(r.Context(), s, id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTokensRevoke(t *testing.T) {
+	xtreamEnv(t)
+	t.Setenv("PLAYLIST_SECRET", "secret")
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	t.Setenv("STORE_DIR", t.TempDir())
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://iptv.example.com"+target, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		Tokens(w, r)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/tokens", `{"subject":"alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue: got %d: %s", w.Code, w.Body)
+	}
+	var issued issueResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &issued); err != nil {
+		t.Fatal(err)
+	}
+	playlist := func() int {
+		w := httptest.NewRecorder()
+		M3u(w, httptest.NewRequest(http.MethodGet, "http://iptv.example.com/api/m3u?token="+issued.Token, nil))
+		return w.Code
+	}
+	if code := playlist(); code != http.StatusOK {
+		t.Fatalf("playlist with a new token: got %d", code)
+	}
+
+	// Without a persistent store the revocation would be forgotten.
+	t.Setenv("STORE_DIR", "")
+	if w := do(http.MethodDelete, "/api/tokens?id="+issued.ID, ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("revoke without a durable store: got %d, want 503", w.Code)
+	}
+
+	t.Setenv("STORE_DIR", t.TempDir())
+	if w := do(http.MethodDelete, "/api/tokens?id="+issued.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodGet, "/api/tokens?id="+issued.ID, ""); !strings.Contains(w.Body.String(), `"revoked":true`) {
+		t.Errorf("status after revoking: got %s", w.Body)
+	}
+	if code := playlist(); code != http.StatusUnauthorized {
+		t.Errorf("playlist with a revoked token: got %d, want 401", code)
+	}
+}
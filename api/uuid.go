@@ -5,13 +5,20 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/middleware"
 )
 
 func TestUUID(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "uuid", serveTestUUID)
+}
+
+func serveTestUUID(w http.ResponseWriter, r *http.Request) {
 	// Creating UUID Version 4
 	id := uuid.New()
-	// Print the UUID to the console
-	fmt.Println(id.String())
+	// Log the UUID
+	logging.From(r.Context()).Info("generated uuid", "uuid", id.String())
 	// Print the UUID to the response
 	fmt.Fprint(w, id.String())
 }
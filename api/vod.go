@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/m3u"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/ratelimit"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/vod"
+	"template-go-vercel/pkg/web"
+)
+
+// Vod serves the on-demand catalogue as JSON. ?view=categories lists the
+// categories, ?category= limits the series to one category, ?series= returns
+// a single series and ?format=m3u returns a playlist grouped by series.
+func Vod(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "vod", serveVod)
+}
+
+func serveVod(w http.ResponseWriter, r *http.Request) {
+	// The token is checked before anything is fetched, so unauthorized
+	// callers cannot make the function load the upstream feed.
+	profile, tok, err := token.Authorize(r)
+	if err != nil {
+		http.Error(w, err.Error(), token.Status(err))
+		return
+	}
+	if !ratelimit.Check(w, r, "vod", tok) {
+		return
+	}
+
+	src, err := source.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		fetch.Fail(w, r, "loading channels from MEDIA_URL", err)
+		return
+	}
+	_, items := vod.Split(channels)
+	items, _ = policy.Apply(items, profile)
+	if tok != "" {
+		for i := range items {
+			items[i].StreamURL = token.StreamURL(web.BaseURL(r), items[i].ID, tok)
+		}
+	}
+	catalogue := vod.Build(items)
+
+	q := r.URL.Query()
+	if q.Get("format") == "m3u" {
+		w.Header().Set("Content-Type", m3u.ContentType)
+		w.Write((&m3u.M3UData{List: catalogue.Playlist()}).M3UData())
+		return
+	}
+
+	var resp interface{} = catalogue
+	switch {
+	case q.Get("series") != "":
+		series, ok := catalogue.Find(q.Get("series"))
+		if !ok {
+			http.Error(w, "series not found", http.StatusNotFound)
+			return
+		}
+		resp = series
+	case q.Get("category") != "":
+		resp = catalogue.InCategory(q.Get("category"))
+	case q.Get("view") == "categories":
+		resp = catalogue.Categories
+	case q.Get("view") == "series":
+		resp = catalogue.Series
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		logging.From(r.Context()).Error("JSON marshal failed", "error", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
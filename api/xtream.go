@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/changes"
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/logo"
+	"template-go-vercel/pkg/middleware"
+	"template-go-vercel/pkg/numbering"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/ratelimit"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/vod"
+	"template-go-vercel/pkg/web"
+	"template-go-vercel/pkg/xmltv"
+	"template-go-vercel/pkg/xtream"
+)
+
+// Xtream serves an Xtream Codes compatible API. vercel.json rewrites
+// /player_api.php, /xmltv.php and /live/{user}/{pass}/{id}.m3u8 here with
+// the endpoint query parameter set to player_api, xmltv or live.
+func Xtream(w http.ResponseWriter, r *http.Request) {
+	middleware.Serve(w, r, "xtream", serveXtream)
+}
+
+func serveXtream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	profile, tok, err := xtream.Authorize(r)
+	switch {
+	case err == xtream.ErrLogin || token.Status(err) == http.StatusUnauthorized:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"user_info":{"auth":0}}`))
+		return
+	case err != nil:
+		http.Error(w, err.Error(), token.Status(err))
+		return
+	}
+	if !ratelimit.Check(w, r, xtreamLimit(q), tok) {
+		return
+	}
+
+	src, err := source.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channels, err := src.Channels(r.Context())
+	if err != nil {
+		fetch.Fail(w, r, "loading channels from MEDIA_URL", err)
+		return
+	}
+
+	channels, _ = vod.Split(channels)
+	changes.Track(r.Context(), channels)
+
+	numberer, err := numbering.FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := numberer.Assign(r.Context(), channels); err != nil {
+		logging.From(r.Context()).Warn("assigning channel numbers failed", "error", err)
+	}
+	channels = override.FromStore(r.Context(), store.FromEnv(), channels)
+
+	channels, _ = policy.Apply(channels, profile)
+	if tok != "" {
+		// Private deployments hand out signed /api/stream links, which
+		// check the token again, instead of the upstream URLs.
+		for i := range channels {
+			channels[i].StreamURL = token.StreamURL(web.BaseURL(r), channels[i].ID, tok)
+			channels[i].GuideURL = ""
+		}
+	}
+
+	if logo.Enabled(r.URL.Query()) {
+		logo.Rewrite(channels, web.BaseURL(r), logo.DefaultSize())
+	}
+
+	switch q.Get("endpoint") {
+	case "xmltv":
+		xmlData, err := xmltv.Generate(channels)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error generating XMLTV data: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", xmltv.ContentType)
+		w.Write(xmlData)
+	case "live":
+		id, err := strconv.Atoi(strings.TrimSuffix(q.Get("id"), path.Ext(q.Get("id"))))
+		if err != nil {
+			http.Error(w, "invalid stream id", http.StatusBadRequest)
+			return
+		}
+		ch, ok := xtream.Find(channels, id)
+		if !ok || ch.StreamURL == "" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, ch.StreamURL, http.StatusFound)
+	default:
+		writeXtreamJSON(w, r, playerAPI(r, channels))
+	}
+}
+
+// xtreamLimit names the rate limit of an Xtream request after the handler
+// serving the same data: the guide counts against RATE_LIMIT_XMLTV, stream
+// redirects against RATE_LIMIT_STREAM and the rest of the API, which
+// serves the playlist, against RATE_LIMIT_M3U.
+func xtreamLimit(q url.Values) string {
+	switch {
+	case q.Get("endpoint") == "live":
+		return "stream"
+	case q.Get("endpoint") == "xmltv", q.Get("action") == "get_short_epg", q.Get("action") == "get_simple_data_table":
+		return "xmltv"
+	}
+	return "m3u"
+}
+
+func playerAPI(r *http.Request, channels []source.Channel) interface{} {
+	q := r.URL.Query()
+	switch q.Get("action") {
+	case "get_live_categories":
+		return xtream.LiveCategories(channels)
+	case "get_live_streams":
+		return xtream.LiveStreams(channels, q.Get("category_id"))
+	case "get_short_epg", "get_simple_data_table":
+		id, _ := strconv.Atoi(q.Get("stream_id"))
+		limit, err := strconv.Atoi(q.Get("limit"))
+		if err != nil {
+			limit = 4
+		}
+		if q.Get("action") == "get_simple_data_table" {
+			limit = 0
+		}
+		ch, _ := xtream.Find(channels, id)
+		return xtream.NewShortEPG(ch, limit, time.Now())
+	case "get_vod_categories", "get_vod_streams", "get_series_categories", "get_series":
+		return []struct{}{}
+	default:
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		return xtream.NewLogin(q.Get("username"), q.Get("password"), scheme, r.Host, time.Now())
+	}
+}
+
+func writeXtreamJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		logging.From(r.Context()).Error("JSON marshal failed", "error", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/token"
+	"template-go-vercel/pkg/xtream"
+)
+
+// xtreamEnv serves the static fixture shared with the source adapters.
+func xtreamEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("MEDIA_URL", "../pkg/source/testdata/static.json")
+	t.Setenv("SOURCE_TYPE", "static")
+	t.Setenv("REDIS_URL", "")
+	t.Setenv("STORE_DIR", "")
+	t.Setenv("PLAYLIST_SECRET", "")
+	t.Setenv("XTREAM_USERNAME", "user")
+	t.Setenv("XTREAM_PASSWORD", "pass")
+}
+
+func serveXtreamTest(target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	Xtream(w, httptest.NewRequest(http.MethodGet, "http://iptv.example.com"+target, nil))
+	return w
+}
+
+func TestXtreamLogin(t *testing.T) {
+	xtreamEnv(t)
+
+	w := serveXtreamTest("/player_api.php?username=user&password=pass")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: got %d: %s", w.Code, w.Body)
+	}
+	var login xtream.Login
+	if err := json.Unmarshal(w.Body.Bytes(), &login); err != nil {
+		t.Fatal(err)
+	}
+	if login.UserInfo.Auth != 1 || login.ServerInfo.URL != "iptv.example.com" {
+		t.Errorf("login: got %+v", login)
+	}
+
+	w = serveXtreamTest("/player_api.php?username=user&password=nope")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"auth":0`) {
+		t.Errorf("wrong password: got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestXtreamLiveStreams(t *testing.T) {
+	xtreamEnv(t)
+
+	w := serveXtreamTest("/player_api.php?username=user&password=pass&action=get_live_streams")
+	if w.Code != http.StatusOK {
+		t.Fatalf("get_live_streams: got %d: %s", w.Code, w.Body)
+	}
+	var streams []xtream.Stream
+	if err := json.Unmarshal(w.Body.Bytes(), &streams); err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 || streams[0].EPGChannelID != "tvj" || streams[0].CategoryID != xtream.CategoryID("News") {
+		t.Errorf("get_live_streams: got %+v", streams)
+	}
+
+	w = serveXtreamTest("/live?endpoint=live&username=user&password=pass&id=" + strconv.Itoa(xtream.StreamID("tvj")) + ".m3u8")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://cdn.example.com/tvj.m3u8" {
+		t.Errorf("live: got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = serveXtreamTest("/xmltv.php?endpoint=xmltv&username=user&password=pass")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<channel id="tvj">`) {
+		t.Errorf("xmltv: got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestXtreamTokenLogin(t *testing.T) {
+	xtreamEnv(t)
+	t.Setenv("PLAYLIST_SECRET", "secret")
+
+	tok, _, err := token.Issue("alice", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := "username=alice&password=" + url.QueryEscape(tok)
+
+	if w := serveXtreamTest("/player_api.php?username=user&password=pass"); w.Code != http.StatusUnauthorized {
+		t.Errorf("shared credentials with PLAYLIST_SECRET: got %d, want 401", w.Code)
+	}
+	if w := serveXtreamTest("/player_api.php?username=bob&password=" + url.QueryEscape(tok)); w.Code != http.StatusUnauthorized {
+		t.Errorf("token of another user: got %d, want 401", w.Code)
+	}
+
+	w := serveXtreamTest("/live?endpoint=live&" + creds + "&id=" + strconv.Itoa(xtream.StreamID("tvj")))
+	want := token.StreamURL("http://iptv.example.com", "tvj", tok)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != want {
+		t.Errorf("live: got %d to %q, want the signed %q", w.Code, w.Header().Get("Location"), want)
+	}
+
+	w = serveXtreamTest("/xmltv.php?endpoint=xmltv&" + creds)
+	if strings.Contains(w.Body.String(), "cdn.example.com") {
+		t.Errorf("xmltv exposes the upstream URL: %s", w.Body)
+	}
+}
+
+func TestXtreamRateLimit(t *testing.T) {
+	xtreamEnv(t)
+	t.Setenv("GEO_PLATFORM", "direct")
+	t.Setenv("RATE_LIMIT", "")
+	t.Setenv("RATE_LIMIT_M3U", "2/1m")
+	t.Setenv("RATE_LIMIT_XMLTV", "1/1m")
+
+	do := func(target string) int {
+		r := httptest.NewRequest(http.MethodGet, "http://iptv.example.com"+target, nil)
+		r.RemoteAddr = "198.51.100.7:4321"
+		w := httptest.NewRecorder()
+		Xtream(w, r)
+		return w.Code
+	}
+	streams := "/player_api.php?username=user&password=pass&action=get_live_streams"
+	guide := "/xmltv.php?endpoint=xmltv&username=user&password=pass"
+
+	if code := do(streams); code != http.StatusOK {
+		t.Fatalf("first request: got %d", code)
+	}
+	if code := do(guide); code != http.StatusOK {
+		t.Fatalf("guide: got %d", code)
+	}
+	if code := do(guide); code != http.StatusTooManyRequests {
+		t.Errorf("guide over RATE_LIMIT_XMLTV: got %d, want 429", code)
+	}
+	if code := do(streams); code != http.StatusOK {
+		t.Errorf("second stream list: got %d", code)
+	}
+	if code := do(streams); code != http.StatusTooManyRequests {
+		t.Errorf("stream list over RATE_LIMIT_M3U: got %d, want 429", code)
+	}
+}
+
+func TestLogoRateLimit(t *testing.T) {
+	xtreamEnv(t)
+	t.Setenv("GEO_PLATFORM", "direct")
+	t.Setenv("RATE_LIMIT", "")
+	t.Setenv("RATE_LIMIT_LOGO", "1/1m")
+
+	do := func() int {
+		r := httptest.NewRequest(http.MethodGet, "http://iptv.example.com/api/logo?id=nope", nil)
+		r.RemoteAddr = "198.51.100.8:4321"
+		w := httptest.NewRecorder()
+		Logo(w, r)
+		return w.Code
+	}
+	if code := do(); code == http.StatusTooManyRequests {
+		t.Fatalf("first request: got %d", code)
+	}
+	if code := do(); code != http.StatusTooManyRequests {
+		t.Errorf("second request: got %d, want 429", code)
+	}
+}
@@ -0,0 +1,47 @@
+// Command scheduler sends programme reminders and records lineup changes
+// in a loop, for local development and self-hosted deployments without
+// Vercel cron jobs. It is configured with the same environment variables
+// as the handlers.
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"template-go-vercel/pkg/changes"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/reminder"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/vod"
+	"template-go-vercel/pkg/webhook"
+)
+
+func main() {
+	every := flag.Duration("every", time.Minute, "how often to look for due reminders")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s := store.FromEnv()
+	sched := &reminder.Scheduler{Store: s, Sender: webhook.NewPublic()}
+	slog.Info("sending reminders", "every", every.String())
+	sched.Loop(ctx, *every, func(ctx context.Context) ([]source.Channel, error) {
+		src, err := source.FromEnv()
+		if err != nil {
+			return nil, err
+		}
+		channels, err := src.Channels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		channels, _ = vod.Split(channels)
+		changes.Track(ctx, channels)
+		return override.FromStore(ctx, s, channels), nil
+	})
+}
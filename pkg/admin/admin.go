@@ -0,0 +1,75 @@
+// Package admin builds the data of the channel administration page, which
+// is rendered by package render as the "admin" page.
+package admin
+
+import (
+	"sort"
+	"time"
+
+	"template-go-vercel/pkg/health"
+	"template-go-vercel/pkg/override"
+	"template-go-vercel/pkg/source"
+)
+
+// Row is one channel as shown on the page.
+type Row struct {
+	ID string
+	// Channel has the overrides applied, Feed is the channel as the feed
+	// has it.
+	Channel  source.Channel
+	Feed     source.Channel
+	Override override.Override
+	// Current is the programme on air, if the feed has a guide.
+	Current *source.Programme
+	// Health is nil until streams have been probed.
+	Health *health.Result
+}
+
+// Overridden reports whether the row has an override.
+func (r Row) Overridden() bool {
+	return !r.Override.IsZero()
+}
+
+// Page is the data of the admin page.
+type Page struct {
+	Rows        []Row
+	PlaylistURL string
+	GuideURL    string
+	VODURL      string
+	// Private is set when playlists require a token.
+	Private bool
+	// Checked is set when Rows carry health results.
+	Checked bool
+	Message string
+	Error   string
+}
+
+// Rows pairs channels with their overrides, in channel number order.
+// Hidden channels are included so they can be shown again.
+func Rows(channels []source.Channel, set override.Set, now time.Time) []Row {
+	rows := make([]Row, len(channels))
+	for i, ch := range channels {
+		o := set[ch.ID]
+		shown := o
+		shown.Hidden = false
+		rows[i] = Row{
+			ID:       ch.ID,
+			Channel:  override.Set{ch.ID: shown}.Apply([]source.Channel{ch})[0],
+			Feed:     ch,
+			Override: o,
+			Current:  current(ch.Programmes, now),
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Channel.Number < rows[j].Channel.Number })
+	return rows
+}
+
+func current(programmes []source.Programme, now time.Time) *source.Programme {
+	for i := range programmes {
+		p := &programmes[i]
+		if !now.Before(p.Start) && now.Before(p.End) {
+			return p
+		}
+	}
+	return nil
+}
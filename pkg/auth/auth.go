@@ -0,0 +1,47 @@
+// Package auth guards administrative endpoints.
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Token reports whether r carries the secret held in the environment
+// variable env, either as a bearer token or as the password of HTTP basic
+// auth so browsers can log in. It is always false while env is unset.
+func Token(r *http.Request, env string) bool {
+	want := os.Getenv(env)
+	if want == "" {
+		return false
+	}
+	got := ""
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		got = strings.TrimPrefix(h, "Bearer ")
+	} else if _, pass, ok := r.BasicAuth(); ok {
+		got = pass
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// Admin reports whether r carries ADMIN_TOKEN. Admin access is disabled
+// while ADMIN_TOKEN is unset.
+func Admin(r *http.Request) bool {
+	return Token(r, "ADMIN_TOKEN")
+}
+
+// Unauthorized answers 401 with a basic auth challenge.
+func Unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// RequireAdmin answers 401 and returns false when r is not an admin request.
+func RequireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if Admin(r) {
+		return true
+	}
+	Unauthorized(w)
+	return false
+}
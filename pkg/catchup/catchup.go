@@ -0,0 +1,278 @@
+// Package catchup adds catch-up (timeshift) attributes to playlists and
+// resolves a past programme window to a playable URL.
+//
+// Catch-up is enabled by setting CATCHUP to the mode advertised to players
+// ("default", "append", "shift", "flussonic" or "xc"). CATCHUP_DAYS sets
+// how far back players may go (default 7). CATCHUP_SOURCE overrides the
+// catchup-source template; {id} in it is replaced with the channel id and
+// every other placeholder is left for the player. Without it the
+// catchup-source depends on the mode:
+//
+//	default    /api/catchup/{id}?start={utc}&end={utcend}
+//	append     the start={utc}&end={utcend} query, which players append
+//	           to the stream URL
+//	shift      none; players add utc and lutc to the stream URL
+//	flussonic  none; players derive the archive URL from the stream URL
+//	xc         none; players build Xtream timeshift URLs
+//
+// In append and shift mode, signed /api/stream URLs pass the window on to
+// the resolver (see Window); unsigned upstream URLs must support it natively.
+//
+// The resolver only accepts windows that started in the past and within
+// CATCHUP_DAYS. It first looks for an on-demand item of the same programme,
+// matched by series and title, aired closest to the window, and otherwise
+// expands CATCHUP_UPSTREAM, a template for providers with native timeshift
+// that understands {url}, {id}, {utc}, {utcend} and {duration}.
+package catchup
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/m3u"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/xmltv"
+)
+
+var (
+	// ErrUnknownChannel is returned for ids that are not live channels.
+	ErrUnknownChannel = errors.New("catchup: unknown channel")
+	// ErrNotAvailable is returned when no recording covers the window.
+	ErrNotAvailable = errors.New("catchup: programme not available")
+	// ErrOutOfRange is returned for windows that have not started yet or
+	// started more than CATCHUP_DAYS ago.
+	ErrOutOfRange = errors.New("catchup: window must start in the past and within CATCHUP_DAYS")
+)
+
+// matchWindow is how far an on-demand item's AiredAt may be from the
+// requested window and still be considered a recording of it.
+const matchWindow = 2 * time.Hour
+
+// Config holds the catch-up settings.
+type Config struct {
+	Mode     string
+	Days     int
+	Source   string
+	Upstream string
+	// Now returns the current time; nil means time.Now.
+	Now func() time.Time
+}
+
+// FromEnv reads CATCHUP, CATCHUP_DAYS, CATCHUP_SOURCE and CATCHUP_UPSTREAM.
+func FromEnv() Config {
+	c := Config{
+		Mode:     os.Getenv("CATCHUP"),
+		Days:     7,
+		Source:   os.Getenv("CATCHUP_SOURCE"),
+		Upstream: os.Getenv("CATCHUP_UPSTREAM"),
+	}
+	if n, err := strconv.Atoi(os.Getenv("CATCHUP_DAYS")); err == nil && n > 0 {
+		c.Days = n
+	}
+	return c
+}
+
+// Enabled reports whether playlists should advertise catch-up.
+func (c Config) Enabled() bool {
+	return c.Mode != ""
+}
+
+// Apply sets the catch-up attributes on the entries of list that belong to
+// live channels. tok, when set, is passed on to the resolver.
+func (c Config) Apply(list []*m3u.EXTINF, channels []source.Channel, baseURL, tok string) {
+	if !c.Enabled() {
+		return
+	}
+	live := map[string]bool{}
+	for _, ch := range channels {
+		if ch.IsLive() {
+			live[ch.ID] = true
+		}
+	}
+	for _, inf := range list {
+		if !live[inf.Id] {
+			continue
+		}
+		inf.Catchup = c.Mode
+		inf.CatchupDays = c.Days
+		inf.CatchupSource = c.sourceFor(inf, baseURL, tok)
+	}
+}
+
+// window is the catch-up query players fill in.
+const window = "start={utc}&end={utcend}"
+
+// sourceFor returns the catchup-source of inf. tok is set when the stream
+// URL will be replaced by a signed /api/stream URL, which has a query.
+func (c Config) sourceFor(inf *m3u.EXTINF, baseURL, tok string) string {
+	if c.Source != "" {
+		return strings.ReplaceAll(c.Source, "{id}", url.PathEscape(inf.Id))
+	}
+	switch c.Mode {
+	case "default":
+		return ResolverURL(baseURL, inf.Id, window, tok)
+	case "append":
+		if tok != "" || strings.Contains(inf.Url, "?") {
+			return "&" + window
+		}
+		return "?" + window
+	}
+	return ""
+}
+
+// ResolverURL returns the /api/catchup URL of channelID with the given
+// start and end query.
+func ResolverURL(baseURL, channelID, query, tok string) string {
+	u := baseURL + "/api/catchup/" + url.PathEscape(channelID) + "?" + query
+	if tok != "" {
+		u += "&token=" + url.QueryEscape(tok)
+	}
+	return u
+}
+
+// Window returns the catch-up window players added to a stream URL: start
+// and end in append mode, or utc and lutc (the time of the request) in
+// shift mode. ok is false for live requests.
+func Window(q url.Values) (start, end string, ok bool) {
+	if s := q.Get("start"); s != "" {
+		return s, q.Get("end"), true
+	}
+	if s := q.Get("utc"); s != "" {
+		return s, q.Get("lutc"), true
+	}
+	return "", "", false
+}
+
+func (c Config) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Check returns ErrOutOfRange unless the window starts in the past and no
+// more than Days ago. A window still on air is cut off at the current time.
+func (c Config) Check(start, end time.Time) (time.Time, error) {
+	now := c.now()
+	if !start.Before(now) || start.Before(now.AddDate(0, 0, -c.Days)) {
+		return end, ErrOutOfRange
+	}
+	if end.After(now) {
+		end = now
+	}
+	return end, nil
+}
+
+// Resolve returns a playable URL for the live channel channelID between
+// start and end.
+func (c Config) Resolve(channels []source.Channel, channelID string, start, end time.Time) (string, error) {
+	end, err := c.Check(start, end)
+	if err != nil {
+		return "", err
+	}
+	var live *source.Channel
+	for i := range channels {
+		if channels[i].ID == channelID && channels[i].IsLive() {
+			live = &channels[i]
+			break
+		}
+	}
+	if live == nil {
+		return "", ErrUnknownChannel
+	}
+
+	if u := findRecording(channels, *live, programmeAt(*live, start), start, end); u != "" {
+		return u, nil
+	}
+	if c.Upstream != "" && live.StreamURL != "" {
+		return c.expandUpstream(*live, start, end), nil
+	}
+	return "", ErrNotAvailable
+}
+
+// programmeAt returns the title of the programme on air at t, if known.
+func programmeAt(ch source.Channel, t time.Time) string {
+	for _, p := range ch.Programmes {
+		if !t.Before(p.Start) && t.Before(p.End) {
+			return p.Title
+		}
+	}
+	return ""
+}
+
+// findRecording picks the on-demand item aired closest to start among the
+// recordings of the programme. An item is a recording when it belongs to
+// the live channel's series, to a series with an episode named after the
+// programme, or is itself named after it. When neither the title nor the
+// channel's series is known nothing ties an item to the channel, so there
+// is no recording.
+func findRecording(channels []source.Channel, live source.Channel, title string, start, end time.Time) string {
+	title = strings.ToLower(strings.TrimSpace(title))
+	if title == "" && live.SeriesID == "" {
+		return ""
+	}
+	named := func(ch source.Channel) bool {
+		return title != "" && strings.Contains(strings.ToLower(ch.Name), title)
+	}
+	series := map[string]bool{}
+	if live.SeriesID != "" {
+		series[live.SeriesID] = true
+	}
+	for _, ch := range channels {
+		if !ch.IsLive() && ch.SeriesID != "" && named(ch) {
+			series[ch.SeriesID] = true
+		}
+	}
+	recording := func(ch source.Channel) bool {
+		return (ch.SeriesID != "" && series[ch.SeriesID]) || named(ch)
+	}
+
+	var best string
+	var bestDelta time.Duration
+	for _, ch := range channels {
+		if ch.IsLive() || ch.AiredAt.IsZero() || ch.StreamURL == "" {
+			continue
+		}
+		if ch.AiredAt.Before(start.Add(-matchWindow)) || ch.AiredAt.After(end.Add(matchWindow)) {
+			continue
+		}
+		if !recording(ch) {
+			continue
+		}
+		delta := ch.AiredAt.Sub(start)
+		if delta < 0 {
+			delta = -delta
+		}
+		if best == "" || delta < bestDelta {
+			best, bestDelta = ch.StreamURL, delta
+		}
+	}
+	return best
+}
+
+func (c Config) expandUpstream(ch source.Channel, start, end time.Time) string {
+	return strings.NewReplacer(
+		"{url}", ch.StreamURL,
+		"{id}", url.PathEscape(ch.ID),
+		"{utc}", strconv.FormatInt(start.Unix(), 10),
+		"{utcend}", strconv.FormatInt(end.Unix(), 10),
+		"{duration}", strconv.FormatInt(int64(end.Sub(start).Seconds()), 10),
+	).Replace(c.Upstream)
+}
+
+// ParseTime accepts unix seconds, RFC 3339 and XMLTV timestamps.
+func ParseTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && len(s) != 14 {
+		return time.Unix(n, 0).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC3339, xmltv.TimeFormat, "20060102150405"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("catchup: unrecognised time " + strconv.Quote(s))
+}
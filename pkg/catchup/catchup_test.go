@@ -0,0 +1,150 @@
+package catchup
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/m3u"
+	"template-go-vercel/pkg/source"
+)
+
+var now = time.Date(2026, 10, 18, 22, 0, 0, 0, time.UTC)
+
+func config() Config {
+	return Config{Mode: "default", Days: 7, Now: func() time.Time { return now }}
+}
+
+func TestSourceFor(t *testing.T) {
+	base := "https://iptv.example.com"
+	tests := []struct {
+		mode, source, url, tok, want string
+	}{
+		{mode: "default", want: base + "/api/catchup/tvj?start={utc}&end={utcend}"},
+		{mode: "default", tok: "t", want: base + "/api/catchup/tvj?start={utc}&end={utcend}&token=t"},
+		{mode: "append", url: "https://cdn.example.com/tvj.m3u8", want: "?start={utc}&end={utcend}"},
+		{mode: "append", url: "https://cdn.example.com/tvj.m3u8?k=v", want: "&start={utc}&end={utcend}"},
+		{mode: "append", url: "https://cdn.example.com/tvj.m3u8", tok: "t", want: "&start={utc}&end={utcend}"},
+		{mode: "shift", want: ""},
+		{mode: "flussonic", want: ""},
+		{mode: "shift", source: "https://tv.example.com/{id}?utc={utc}", want: "https://tv.example.com/tvj?utc={utc}"},
+	}
+	for _, tt := range tests {
+		c := Config{Mode: tt.mode, Source: tt.source}
+		got := c.sourceFor(&m3u.EXTINF{Id: "tvj", Url: tt.url}, base, tt.tok)
+		if got != tt.want {
+			t.Errorf("%s with url %q, token %q: got %q, want %q", tt.mode, tt.url, tt.tok, got, tt.want)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	for _, tt := range []struct {
+		query, start, end string
+		ok                bool
+	}{
+		{query: "token=t", ok: false},
+		{query: "token=t&start=1&end=2", start: "1", end: "2", ok: true},
+		{query: "token=t&utc=1&lutc=3", start: "1", end: "3", ok: true},
+	} {
+		q, _ := url.ParseQuery(tt.query)
+		start, end, ok := Window(q)
+		if start != tt.start || end != tt.end || ok != tt.ok {
+			t.Errorf("%s: got %q, %q, %t", tt.query, start, end, ok)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := config()
+	tests := []struct {
+		name       string
+		start, end time.Time
+		wantEnd    time.Time
+		wantErr    error
+	}{
+		{"past", now.Add(-2 * time.Hour), now.Add(-time.Hour), now.Add(-time.Hour), nil},
+		{"on air", now.Add(-time.Hour), now.Add(time.Hour), now, nil},
+		{"future", now.Add(time.Hour), now.Add(2 * time.Hour), time.Time{}, ErrOutOfRange},
+		{"too old", now.AddDate(0, 0, -8), now.AddDate(0, 0, -8).Add(time.Hour), time.Time{}, ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		end, err := c.Check(tt.start, tt.end)
+		if err != tt.wantErr {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !end.Equal(tt.wantEnd) {
+			t.Errorf("%s: got end %v, want %v", tt.name, end, tt.wantEnd)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	start := now.Add(-3 * time.Hour)
+	end := start.Add(time.Hour)
+	live := source.Channel{ID: "tvj", StreamURL: "https://cdn.example.com/tvj.m3u8", Programmes: []source.Programme{
+		{Title: "Prime Time News", Start: start, End: end},
+	}}
+	vod := func(id, name, series string, aired time.Time) source.Channel {
+		return source.Channel{ID: id, Name: name, MediaType: "vod", SeriesID: series, AiredAt: aired,
+			StreamURL: "https://cdn.example.com/" + id + ".m3u8"}
+	}
+	channels := []source.Channel{
+		live,
+		// Closest to the window, but another programme.
+		vod("sport", "Sports Desk", "s2", start),
+		// Same series as a titled episode, named by date only.
+		vod("news-1018", "18 October 2026", "s1", start.Add(5*time.Minute)),
+		vod("news-1017", "Prime Time News 17 October", "s1", start.AddDate(0, 0, -1)),
+	}
+	c := config()
+
+	got, err := c.Resolve(channels, "tvj", start, end)
+	if err != nil || got != "https://cdn.example.com/news-1018.m3u8" {
+		t.Errorf("series match: got %q, %v", got, err)
+	}
+
+	// The live channel's own series is used when the title is unknown.
+	c.Upstream = ""
+	untitled := append([]source.Channel{{ID: "tvj", SeriesID: "s1"}}, channels[1:]...)
+	if got, err := c.Resolve(untitled, "tvj", start, end); err != nil || got != "https://cdn.example.com/news-1018.m3u8" {
+		t.Errorf("channel series: got %q, %v", got, err)
+	}
+
+	c.Upstream = "{url}?utc={utc}&duration={duration}"
+	got, err = c.Resolve([]source.Channel{live}, "tvj", start, end)
+	want := "https://cdn.example.com/tvj.m3u8?utc=" + strconv.FormatInt(start.Unix(), 10) + "&duration=3600"
+	if err != nil || got != want {
+		t.Errorf("upstream: got %q, %v; want %q", got, err, want)
+	}
+
+	if _, err := c.Resolve(channels, "tvj", now.Add(time.Hour), now.Add(2*time.Hour)); err != ErrOutOfRange {
+		t.Errorf("future window: got %v, want ErrOutOfRange", err)
+	}
+	if _, err := c.Resolve(channels, "nope", start, end); err != ErrUnknownChannel {
+		t.Errorf("unknown channel: got %v, want ErrUnknownChannel", err)
+	}
+}
+
+func TestResolveUnrelatedItem(t *testing.T) {
+	start := now.Add(-3 * time.Hour)
+	end := start.Add(time.Hour)
+	// Neither a programme title nor a series ties anything to the channel.
+	channels := []source.Channel{
+		{ID: "tvj", StreamURL: "https://cdn.example.com/tvj.m3u8"},
+		{ID: "cooking", Name: "Cooking Show", MediaType: "vod", SeriesID: "s9", AiredAt: start,
+			StreamURL: "https://cdn.example.com/cooking.m3u8"},
+	}
+	c := config()
+
+	if got, err := c.Resolve(channels, "tvj", start, end); err != ErrNotAvailable {
+		t.Errorf("without CATCHUP_UPSTREAM: got %q, %v; want ErrNotAvailable", got, err)
+	}
+	c.Upstream = "{url}?utc={utc}"
+	want := "https://cdn.example.com/tvj.m3u8?utc=" + strconv.FormatInt(start.Unix(), 10)
+	if got, err := c.Resolve(channels, "tvj", start, end); err != nil || got != want {
+		t.Errorf("with CATCHUP_UPSTREAM: got %q, %v; want %q", got, err, want)
+	}
+}
@@ -0,0 +1,290 @@
+// Package changes detects channel lineup changes between feed fetches.
+//
+// The playlist, guide and Xtream handlers call Track after each feed parse,
+// and the scheduler (/api/scheduler) does on every run, so changes are
+// found even when nobody pulls a playlist. Record compares the live
+// channels with the previous snapshot in the store, which costs one read
+// while nothing changes. Differences are appended to a bounded history
+// and, when CHANGES_WEBHOOK_URL is set, posted as a "lineup.changed"
+// webhook. The history is written before the snapshot is advanced, with
+// atomic store updates, so of two calls seeing the same change only one
+// records it and a failed write never loses it. Stream URLs are compared
+// without their query string, since many CDNs sign URLs with short-lived
+// query parameters.
+package changes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/webhook"
+)
+
+// Event is the webhook event name of lineup changes.
+const Event = "lineup.changed"
+
+const (
+	snapshotKey = "changes:snapshot"
+	historyKey  = "changes:history"
+)
+
+// MaxHistory is the number of entries kept.
+const MaxHistory = 200
+
+// Kind classifies a change.
+type Kind string
+
+const (
+	Added       Kind = "added"
+	Removed     Kind = "removed"
+	Renamed     Kind = "renamed"
+	URLChanged  Kind = "url-changed"
+	LogoChanged Kind = "logo-changed"
+)
+
+// Change is one difference for one channel.
+type Change struct {
+	Kind      Kind   `json:"kind"`
+	ChannelID string `json:"channel_id"`
+	Name      string `json:"name"`
+	Old       string `json:"old,omitempty"`
+	New       string `json:"new,omitempty"`
+}
+
+// Entry is the set of changes found by one fetch.
+type Entry struct {
+	ID      string    `json:"id"`
+	At      time.Time `json:"at"`
+	Changes []Change  `json:"changes"`
+}
+
+// State is what is remembered of a channel.
+type State struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Logo string `json:"logo,omitempty"`
+}
+
+// Snapshot maps channel ids to their state.
+type Snapshot map[string]State
+
+// Take snapshots channels.
+func Take(channels []source.Channel) Snapshot {
+	s := Snapshot{}
+	for _, ch := range channels {
+		s[ch.ID] = State{Name: ch.Name, URL: ch.StreamURL, Logo: ch.Logo}
+	}
+	return s
+}
+
+// Diff lists the changes from prev to next, ordered by channel id.
+func Diff(prev, next Snapshot) []Change {
+	var out []Change
+	for id, n := range next {
+		p, ok := prev[id]
+		if !ok {
+			out = append(out, Change{Kind: Added, ChannelID: id, Name: n.Name, New: n.URL})
+			continue
+		}
+		if p.Name != n.Name {
+			out = append(out, Change{Kind: Renamed, ChannelID: id, Name: n.Name, Old: p.Name, New: n.Name})
+		}
+		if withoutQuery(p.URL) != withoutQuery(n.URL) {
+			out = append(out, Change{Kind: URLChanged, ChannelID: id, Name: n.Name, Old: p.URL, New: n.URL})
+		}
+		if p.Logo != n.Logo {
+			out = append(out, Change{Kind: LogoChanged, ChannelID: id, Name: n.Name, Old: p.Logo, New: n.Logo})
+		}
+	}
+	for id, p := range prev {
+		if _, ok := next[id]; !ok {
+			out = append(out, Change{Kind: Removed, ChannelID: id, Name: p.Name, Old: p.URL})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].ChannelID != out[j].ChannelID {
+			return out[i].ChannelID < out[j].ChannelID
+		}
+		return out[i].Kind < out[j].Kind
+	})
+	return out
+}
+
+func withoutQuery(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// Recorder stores snapshots and history and sends notifications.
+type Recorder struct {
+	Store store.Store
+	// Sender and WebhookURL deliver notifications; none are sent while
+	// WebhookURL is empty.
+	Sender     *webhook.Sender
+	WebhookURL string
+	// Now returns the current time; tests may replace it.
+	Now func() time.Time
+}
+
+// FromEnv returns a Recorder on the configured store that notifies
+// CHANGES_WEBHOOK_URL.
+func FromEnv() *Recorder {
+	return &Recorder{
+		Store:      store.FromEnv(),
+		Sender:     webhook.New(),
+		WebhookURL: os.Getenv("CHANGES_WEBHOOK_URL"),
+	}
+}
+
+// Record compares channels with the previous snapshot and stores the
+// result. It returns the new history entry, or nil when nothing changed or
+// another call already recorded the change. The first call only stores the
+// baseline.
+//
+// The entry is written to the history before the snapshot is advanced, so
+// a failed write leaves the change to be found again by the next call. An
+// entry's ID is derived from the two snapshots it compares, which lets a
+// retry or an overlapping call see that the change is already recorded.
+func (r *Recorder) Record(ctx context.Context, channels []source.Channel) (*Entry, error) {
+	snap := Take(channels)
+	next, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	old, err := r.Store.Get(ctx, snapshotKey)
+	switch {
+	case err == store.ErrNotFound:
+		old = nil
+	case err != nil:
+		return nil, fmt.Errorf("loading lineup snapshot: %w", err)
+	}
+
+	var entry *Entry
+	if old != nil {
+		var prev Snapshot
+		if err := json.Unmarshal(old, &prev); err != nil {
+			return nil, fmt.Errorf("decoding lineup snapshot: %w", err)
+		}
+		diff := Diff(prev, snap)
+		if len(diff) == 0 {
+			return nil, nil
+		}
+		now := time.Now()
+		if r.Now != nil {
+			now = r.Now()
+		}
+		entry = &Entry{ID: entryID(old, next), At: now.UTC(), Changes: diff}
+		if entry, err = r.appendHistory(ctx, entry); err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			r.notify(ctx, entry)
+		}
+	}
+
+	// Advance the snapshot only from the one the diff was made against;
+	// if another call got there first, it has recorded the same change.
+	err = r.Store.Update(ctx, snapshotKey, func(cur []byte) ([]byte, error) {
+		if !bytes.Equal(cur, old) {
+			return nil, nil
+		}
+		return next, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("saving lineup snapshot: %w", err)
+	}
+	return entry, nil
+}
+
+// entryID identifies the change from the snapshot prev to next.
+func entryID(prev, next []byte) string {
+	h := sha256.New()
+	h.Write(prev)
+	h.Write([]byte{0})
+	h.Write(next)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// appendHistory adds entry to the history. It returns nil without writing
+// when the newest entry already is the same change.
+func (r *Recorder) appendHistory(ctx context.Context, entry *Entry) (*Entry, error) {
+	added := false
+	err := r.Store.Update(ctx, historyKey, func(old []byte) ([]byte, error) {
+		added = false
+		var history []Entry
+		if old != nil {
+			if err := json.Unmarshal(old, &history); err != nil {
+				return nil, fmt.Errorf("decoding lineup history: %w", err)
+			}
+		}
+		if len(history) > 0 && history[0].ID == entry.ID {
+			return nil, nil
+		}
+		history = append([]Entry{*entry}, history...)
+		if len(history) > MaxHistory {
+			history = history[:MaxHistory]
+		}
+		added = true
+		return json.Marshal(history)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("saving lineup history: %w", err)
+	}
+	if !added {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// notify posts entry to WebhookURL, logging failures.
+func (r *Recorder) notify(ctx context.Context, entry *Entry) {
+	if r.WebhookURL == "" || r.Sender == nil {
+		return
+	}
+	if err := r.Sender.Send(ctx, r.WebhookURL, Event, struct {
+		Event string `json:"event"`
+		*Entry
+	}{Event, entry}); err != nil {
+		logging.From(ctx).Warn("sending lineup change webhook failed", "error", err)
+	}
+}
+
+// History returns the stored entries, newest first.
+func History(ctx context.Context, s store.Store) ([]Entry, error) {
+	var history []Entry
+	b, err := s.Get(ctx, historyKey)
+	switch {
+	case err == store.ErrNotFound:
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("loading lineup history: %w", err)
+	}
+	if err := json.Unmarshal(b, &history); err != nil {
+		return nil, fmt.Errorf("decoding lineup history: %w", err)
+	}
+	return history, nil
+}
+
+// Track records channels with the environment's Recorder and logs any
+// error, for handlers, which must not fail because of it.
+func Track(ctx context.Context, channels []source.Channel) {
+	if _, err := FromEnv().Record(ctx, channels); err != nil {
+		logging.From(ctx).Warn("recording lineup changes failed", "error", err)
+	}
+}
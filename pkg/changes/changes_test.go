@@ -0,0 +1,159 @@
+package changes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+)
+
+func TestDiff(t *testing.T) {
+	prev := Snapshot{
+		"tvj": {Name: "TVJ", URL: "https://cdn.example.com/tvj.m3u8?sig=1"},
+		"cvm": {Name: "CVM", URL: "https://cdn.example.com/cvm.m3u8"},
+	}
+	next := Snapshot{
+		"tvj": {Name: "TVJ HD", URL: "https://cdn.example.com/tvj.m3u8?sig=2"},
+		"sky": {Name: "Sky", URL: "https://cdn.example.com/sky.m3u8"},
+	}
+	got := Diff(prev, next)
+	want := []Change{
+		{Kind: Removed, ChannelID: "cvm", Name: "CVM", Old: "https://cdn.example.com/cvm.m3u8"},
+		{Kind: Added, ChannelID: "sky", Name: "Sky", New: "https://cdn.example.com/sky.m3u8"},
+		{Kind: Renamed, ChannelID: "tvj", Name: "TVJ HD", Old: "TVJ", New: "TVJ HD"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	r := &Recorder{Store: s}
+
+	channels := []source.Channel{{ID: "tvj", Name: "TVJ", StreamURL: "https://cdn.example.com/tvj.m3u8"}}
+	if e, err := r.Record(ctx, channels); err != nil || e != nil {
+		t.Fatalf("baseline: got %+v, %v", e, err)
+	}
+	if e, err := r.Record(ctx, channels); err != nil || e != nil {
+		t.Fatalf("unchanged lineup: got %+v, %v", e, err)
+	}
+
+	channels = append(channels, source.Channel{ID: "cvm", Name: "CVM"})
+	e, err := r.Record(ctx, channels)
+	if err != nil || e == nil || len(e.Changes) != 1 || e.Changes[0].Kind != Added {
+		t.Fatalf("added channel: got %+v, %v", e, err)
+	}
+	history, err := History(ctx, s)
+	if err != nil || len(history) != 1 || history[0].ID != e.ID {
+		t.Errorf("history: got %+v, %v", history, err)
+	}
+}
+
+func TestRecordConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	r := &Recorder{Store: s}
+	if _, err := r.Record(ctx, []source.Channel{{ID: "tvj", Name: "TVJ"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Runs that overlap and see the same change record it once.
+	channels := []source.Channel{{ID: "tvj", Name: "TVJ"}, {ID: "cvm", Name: "CVM"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Record(ctx, channels); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	history, err := History(ctx, s)
+	if err != nil || len(history) != 1 {
+		t.Errorf("history: got %d entries (%v), want 1", len(history), err)
+	}
+}
+
+// failingStore fails updates of the key in fail.
+type failingStore struct {
+	store.Store
+	fail string
+}
+
+func (f *failingStore) Update(ctx context.Context, key string, fn store.UpdateFunc) error {
+	if key == f.fail {
+		return errors.New("store unreachable")
+	}
+	return f.Store.Update(ctx, key, fn)
+}
+
+func TestRecordFailedWrites(t *testing.T) {
+	ctx := context.Background()
+	s := &failingStore{Store: store.NewMemory()}
+	r := &Recorder{Store: s}
+	if _, err := r.Record(ctx, []source.Channel{{ID: "tvj", Name: "TVJ"}}); err != nil {
+		t.Fatal(err)
+	}
+	channels := []source.Channel{{ID: "tvj", Name: "TVJ"}, {ID: "cvm", Name: "CVM"}}
+
+	// A change whose history write fails is found again.
+	s.fail = historyKey
+	if _, err := r.Record(ctx, channels); err == nil {
+		t.Fatal("failed history write: got no error")
+	}
+	s.fail = ""
+	if e, err := r.Record(ctx, channels); err != nil || e == nil || len(e.Changes) != 1 {
+		t.Fatalf("after a failed history write: got %+v, %v", e, err)
+	}
+
+	// A change recorded before the snapshot write failed is not recorded
+	// twice.
+	channels = channels[:1]
+	s.fail = snapshotKey
+	if _, err := r.Record(ctx, channels); err == nil {
+		t.Fatal("failed snapshot write: got no error")
+	}
+	s.fail = ""
+	if e, err := r.Record(ctx, channels); err != nil || e != nil {
+		t.Fatalf("after a failed snapshot write: got %+v, %v, want the change already recorded", e, err)
+	}
+	if e, err := r.Record(ctx, channels); err != nil || e != nil {
+		t.Fatalf("after the snapshot caught up: got %+v, %v", e, err)
+	}
+
+	history, err := History(ctx, s)
+	if err != nil || len(history) != 2 || history[0].Changes[0].Kind != Removed || history[1].Changes[0].Kind != Added {
+		t.Errorf("history: got %+v, %v, want the removal after the addition", history, err)
+	}
+}
+
+func TestRecordRevertedChange(t *testing.T) {
+	ctx := context.Background()
+	r := &Recorder{Store: store.NewMemory()}
+	one := []source.Channel{{ID: "tvj", Name: "TVJ"}}
+	two := []source.Channel{{ID: "tvj", Name: "TVJ"}, {ID: "cvm", Name: "CVM"}}
+
+	// A change that is undone and made again is recorded each time.
+	for i, channels := range [][]source.Channel{one, two, one, two} {
+		e, err := r.Record(ctx, channels)
+		if err != nil || (i > 0) != (e != nil) {
+			t.Fatalf("call %d: got %+v, %v", i, e, err)
+		}
+	}
+	if history, err := History(ctx, r.Store); err != nil || len(history) != 3 {
+		t.Errorf("history: got %d entries (%v), want 3", len(history), err)
+	}
+}
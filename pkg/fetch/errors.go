@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"template-go-vercel/pkg/logging"
+)
+
+// ErrBodyTooLarge is returned when a response exceeds Fetcher.MaxBodyBytes.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// StatusError is returned when upstream answers with a non-2xx status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upstream %s returned %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Error wraps the final failure of a fetch together with the number of
+// attempts that were made.
+type Error struct {
+	URL      string
+	Attempts int
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("fetch %s failed after %d attempt(s): %v", e.URL, e.Attempts, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Message describes err for clients. Unlike Error it never includes the
+// upstream URL, which may carry credentials or API keys.
+func Message(err error) string {
+	var se *StatusError
+	switch {
+	case IsTimeout(err):
+		return "upstream timed out"
+	case errors.Is(err, ErrBodyTooLarge):
+		return "upstream response too large"
+	case errors.As(err, &se):
+		return fmt.Sprintf("upstream returned %d %s", se.StatusCode, http.StatusText(se.StatusCode))
+	}
+	return "upstream unavailable"
+}
+
+// Fail logs err, which may name the upstream URL, and answers the request
+// with what failed and a Message, using HTTPStatus.
+func Fail(w http.ResponseWriter, r *http.Request, what string, err error) {
+	logging.From(r.Context()).Error(what+" failed", "error", err)
+	http.Error(w, "Error "+what+": "+Message(err), HTTPStatus(err))
+}
+
+// HTTPStatus maps a fetch error to the status a handler should answer
+// with: 504 for timeouts and 502 for everything else.
+func HTTPStatus(err error) int {
+	if IsTimeout(err) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// IsTimeout reports whether err was caused by a request timing out.
+func IsTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
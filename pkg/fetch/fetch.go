@@ -0,0 +1,212 @@
+// Package fetch is the shared HTTP client used to talk to upstream feeds.
+// It adds per-attempt timeouts, retries with exponential backoff and
+// jitter, status-code checking and response size limits on top of
+// net/http, and propagates the caller's request context.
+package fetch
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/logging"
+)
+
+// Fetcher performs GET requests against upstream services.
+type Fetcher struct {
+	Client *http.Client
+	// Timeout bounds each individual attempt.
+	Timeout time.Duration
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// BaseDelay and MaxDelay bound the exponential backoff between attempts.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxBodyBytes caps the size of a response body.
+	MaxBodyBytes int64
+	// Header is added to every request.
+	Header http.Header
+	// Credentials are added to the requests they apply to, in order.
+	Credentials []Credentials
+}
+
+// Credentials are headers and query parameters that authenticate requests
+// to one upstream.
+type Credentials struct {
+	// Host limits the credentials to requests to this host. Empty sends
+	// them with every request.
+	Host   string
+	Header http.Header
+	Query  url.Values
+}
+
+// appliesTo reports whether c should be sent to u.
+func (c Credentials) appliesTo(u *url.URL) bool {
+	return c.Host == "" || strings.EqualFold(c.Host, u.Host)
+}
+
+// New returns a Fetcher configured from FETCH_TIMEOUT, FETCH_ATTEMPTS and
+// FETCH_MAX_BYTES, falling back to sane defaults.
+func New() *Fetcher {
+	f := &Fetcher{
+		Client:       &http.Client{},
+		Timeout:      4 * time.Second,
+		MaxAttempts:  3,
+		BaseDelay:    250 * time.Millisecond,
+		MaxDelay:     2 * time.Second,
+		MaxBodyBytes: 10 << 20,
+		Header:       http.Header{},
+	}
+	if d, err := time.ParseDuration(os.Getenv("FETCH_TIMEOUT")); err == nil && d > 0 {
+		f.Timeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("FETCH_ATTEMPTS")); err == nil && n > 0 {
+		f.MaxAttempts = n
+	}
+	if n, err := strconv.ParseInt(os.Getenv("FETCH_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		f.MaxBodyBytes = n
+	}
+	return f
+}
+
+// JSON returns a Fetcher that asks upstream for JSON.
+func JSON() *Fetcher {
+	f := New()
+	f.Header.Set("Accept", "application/json")
+	f.Header.Set("Content-Type", "application/json")
+	return f
+}
+
+// Get fetches url and returns the response body. Network errors, 5xx and
+// 429 responses are retried; other non-2xx responses fail immediately with
+// a *StatusError. The returned error is always an *Error.
+func (f *Fetcher) Get(ctx context.Context, url string) ([]byte, error) {
+	attempts := f.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		start := time.Now()
+		body, retryAfter, err := f.do(ctx, url)
+		logger := logging.From(ctx).With("url", url, "attempt", attempt,
+			"duration_ms", time.Since(start).Milliseconds())
+		if err == nil {
+			logger.Info("upstream fetch", "bytes", len(body))
+			return body, nil
+		}
+		lastErr = err
+		if !retryable(err) || attempt == attempts {
+			logger.Warn("upstream fetch failed", "error", err)
+			return nil, &Error{URL: url, Attempts: attempt, Err: err}
+		}
+		logger.Warn("upstream fetch failed, retrying", "error", err)
+
+		delay := f.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if delay > f.MaxDelay {
+			delay = f.MaxDelay
+		}
+		select {
+		case <-ctx.Done():
+			return nil, &Error{URL: url, Attempts: attempt, Err: ctx.Err()}
+		case <-time.After(delay):
+		}
+	}
+	return nil, &Error{URL: url, Attempts: attempts, Err: lastErr}
+}
+
+func (f *Fetcher) do(ctx context.Context, url string) ([]byte, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range f.Header {
+		req.Header[k] = v
+	}
+	for _, c := range f.Credentials {
+		if !c.appliesTo(req.URL) {
+			continue
+		}
+		for k, v := range c.Header {
+			req.Header[k] = v
+		}
+		if len(c.Query) > 0 {
+			q := req.URL.Query()
+			for k, v := range c.Query {
+				q[k] = v
+			}
+			req.URL.RawQuery = q.Encode()
+		}
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	limit := f.MaxBodyBytes
+	if limit <= 0 {
+		limit = 10 << 20
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(body)) > limit {
+		return nil, 0, ErrBodyTooLarge
+	}
+	return body, 0, nil
+}
+
+// backoff returns the delay before the given retry: exponential growth
+// from BaseDelay capped at MaxDelay, with full jitter.
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	d := f.BaseDelay << (attempt - 1)
+	if d <= 0 || d > f.MaxDelay {
+		d = f.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+func retryable(err error) bool {
+	if se, ok := err.(*StatusError); ok {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	if err == ErrBodyTooLarge {
+		return false
+	}
+	return true
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// upstream answers with statuses in turn, repeating the last one, and
+// counts the requests it gets.
+func upstream(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&n, 1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(statuses[i])
+		w.Write([]byte("body"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+func testFetcher() *Fetcher {
+	f := New()
+	f.Timeout = time.Second
+	f.MaxAttempts = 3
+	f.BaseDelay = time.Millisecond
+	f.MaxDelay = 5 * time.Millisecond
+	return f
+}
+
+func TestGetRetriesServerErrors(t *testing.T) {
+	srv, n := upstream(t, nil, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK)
+	body, err := testFetcher().Get(context.Background(), srv.URL)
+	if err != nil || string(body) != "body" {
+		t.Fatalf("got %q, %v", body, err)
+	}
+	if *n != 3 {
+		t.Errorf("got %d requests, want 3", *n)
+	}
+}
+
+func TestGetHonorsRetryAfter(t *testing.T) {
+	srv, n := upstream(t, http.Header{"Retry-After": {"1"}}, http.StatusTooManyRequests, http.StatusOK)
+	f := testFetcher()
+	f.MaxDelay = 5 * time.Second
+	start := time.Now()
+	if _, err := f.Get(context.Background(), srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("retried after %s, want about the 1s of Retry-After", elapsed)
+	}
+	if *n != 2 {
+		t.Errorf("got %d requests, want 2", *n)
+	}
+}
+
+func TestGetClientErrorIsNotRetried(t *testing.T) {
+	srv, n := upstream(t, nil, http.StatusNotFound)
+	_, err := testFetcher().Get(context.Background(), srv.URL)
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Fatalf("got %v, want a 404 StatusError", err)
+	}
+	var fe *Error
+	if !errors.As(err, &fe) || fe.Attempts != 1 || *n != 1 {
+		t.Errorf("got %d requests and error %v, want 1 attempt", *n, err)
+	}
+}
+
+func TestGetAttemptsExhausted(t *testing.T) {
+	srv, n := upstream(t, nil, http.StatusInternalServerError)
+	_, err := testFetcher().Get(context.Background(), srv.URL)
+	var fe *Error
+	if !errors.As(err, &fe) || fe.Attempts != 3 || *n != 3 {
+		t.Fatalf("got %d requests and error %v, want 3 attempts", *n, err)
+	}
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got %v, want the last 500 StatusError", err)
+	}
+}
+
+func TestGetBodyLimit(t *testing.T) {
+	srv, n := upstream(t, nil, http.StatusOK)
+	f := testFetcher()
+	f.MaxBodyBytes = 3
+	_, err := f.Get(context.Background(), srv.URL)
+	if !errors.Is(err, ErrBodyTooLarge) || *n != 1 {
+		t.Errorf("got %d requests and error %v, want ErrBodyTooLarge without retries", *n, err)
+	}
+	f.MaxBodyBytes = 4
+	if body, err := f.Get(context.Background(), srv.URL); err != nil || string(body) != "body" {
+		t.Errorf("body at the limit: got %q, %v", body, err)
+	}
+}
+
+func TestGetCancelledDuringBackoff(t *testing.T) {
+	srv, n := upstream(t, http.Header{"Retry-After": {"10"}}, http.StatusServiceUnavailable)
+	f := testFetcher()
+	f.MaxDelay = 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := f.Get(ctx, srv.URL)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Get returned after %s, want soon after the cancellation", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || *n != 1 {
+		t.Errorf("got %d requests and error %v, want the context error after 1", *n, err)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	timeout := &Error{URL: "https://example.com/feed?key=secret", Attempts: 1, Err: context.DeadlineExceeded}
+	status := &Error{URL: "https://example.com/feed?key=secret", Attempts: 3, Err: &StatusError{URL: "https://example.com/feed?key=secret", StatusCode: 503}}
+	for _, tt := range []struct {
+		err    error
+		status int
+		msg    string
+	}{
+		{timeout, http.StatusGatewayTimeout, "upstream timed out"},
+		{status, http.StatusBadGateway, "upstream returned 503 Service Unavailable"},
+		{&Error{Err: ErrBodyTooLarge}, http.StatusBadGateway, "upstream response too large"},
+		{&Error{Err: errors.New("connection refused")}, http.StatusBadGateway, "upstream unavailable"},
+	} {
+		if got := HTTPStatus(tt.err); got != tt.status {
+			t.Errorf("HTTPStatus(%v): got %d, want %d", tt.err, got, tt.status)
+		}
+
+		w := httptest.NewRecorder()
+		Fail(w, httptest.NewRequest(http.MethodGet, "/", nil), "loading feed", tt.err)
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), "Error loading feed: "+tt.msg) {
+			t.Errorf("Fail(%v): got %d %q", tt.err, w.Code, w.Body)
+		}
+		if strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("Fail(%v) exposes the URL: %q", tt.err, w.Body)
+		}
+	}
+}
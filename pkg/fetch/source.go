@@ -0,0 +1,199 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Source describes the headers, authentication and proxy needed to talk to
+// one upstream.
+type Source struct {
+	Headers       map[string]string `json:"headers,omitempty"`
+	UserAgent     string            `json:"user_agent,omitempty"`
+	Referer       string            `json:"referer,omitempty"`
+	Cookie        string            `json:"cookie,omitempty"`
+	BearerToken   string            `json:"bearer_token,omitempty"`
+	BasicUser     string            `json:"basic_user,omitempty"`
+	BasicPassword string            `json:"basic_password,omitempty"`
+	// APIKeyParam and APIKey add ?<APIKeyParam>=<APIKey> to every URL.
+	APIKeyParam string `json:"api_key_param,omitempty"`
+	APIKey      string `json:"api_key,omitempty"`
+	Proxy       string `json:"proxy,omitempty"`
+}
+
+// LoadSource reads the configuration for the named source. Settings come
+// from the "name" entry of the JSON file at SOURCES_FILE, then from
+// environment variables prefixed with the upper-cased name, for example
+// MEDIA_USER_AGENT, MEDIA_REFERER, MEDIA_COOKIE, MEDIA_BEARER_TOKEN,
+// MEDIA_BASIC_USER, MEDIA_BASIC_PASSWORD, MEDIA_API_KEY_PARAM,
+// MEDIA_API_KEY, MEDIA_PROXY and MEDIA_HEADERS (a JSON object, or one
+// "Name: value" per line, since values such as cookies may contain ";").
+func LoadSource(name string) (Source, error) {
+	var src Source
+	if path := os.Getenv("SOURCES_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return src, fmt.Errorf("reading SOURCES_FILE: %w", err)
+		}
+		var sources map[string]Source
+		if err := json.Unmarshal(b, &sources); err != nil {
+			return src, fmt.Errorf("parsing SOURCES_FILE: %w", err)
+		}
+		src = sources[strings.ToLower(name)]
+	}
+
+	prefix := strings.ToUpper(name) + "_"
+	env := func(key string, dst *string) {
+		if v := os.Getenv(prefix + key); v != "" {
+			*dst = v
+		}
+	}
+	env("USER_AGENT", &src.UserAgent)
+	env("REFERER", &src.Referer)
+	env("COOKIE", &src.Cookie)
+	env("BEARER_TOKEN", &src.BearerToken)
+	env("BASIC_USER", &src.BasicUser)
+	env("BASIC_PASSWORD", &src.BasicPassword)
+	env("API_KEY_PARAM", &src.APIKeyParam)
+	env("API_KEY", &src.APIKey)
+	env("PROXY", &src.Proxy)
+	if v := os.Getenv(prefix + "HEADERS"); v != "" {
+		headers, err := parseHeaders(v)
+		if err != nil {
+			return src, fmt.Errorf("%sHEADERS: %w", prefix, err)
+		}
+		if src.Headers == nil {
+			src.Headers = map[string]string{}
+		}
+		for k, val := range headers {
+			src.Headers[k] = val
+		}
+	}
+	return src, nil
+}
+
+// parseHeaders reads a JSON object of header values, or "Name: value"
+// lines. Errors leave the values out, as they are often secrets.
+func parseHeaders(v string) (map[string]string, error) {
+	headers := map[string]string{}
+	if strings.HasPrefix(strings.TrimSpace(v), "{") {
+		if err := json.Unmarshal([]byte(v), &headers); err != nil {
+			return nil, errors.New("malformed JSON object")
+		}
+		return headers, nil
+	}
+	for i, line := range strings.Split(v, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		k, val, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("line %d: want \"Name: value\"", i+1)
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(val)
+	}
+	return headers, nil
+}
+
+// Apply configures f to send src's headers and credentials and to route
+// requests through src's proxy. The User-Agent and Referer go with every
+// request; custom headers, cookies, authorization and the API key are
+// added as Credentials for every host, see Stream for narrowing them.
+func (f *Fetcher) Apply(src Source) error {
+	if f.Header == nil {
+		f.Header = http.Header{}
+	}
+	if src.UserAgent != "" {
+		f.Header.Set("User-Agent", src.UserAgent)
+	}
+	if src.Referer != "" {
+		f.Header.Set("Referer", src.Referer)
+	}
+
+	creds := Credentials{Header: http.Header{}}
+	for k, v := range src.Headers {
+		creds.Header.Set(k, v)
+	}
+	if src.Cookie != "" {
+		creds.Header.Set("Cookie", src.Cookie)
+	}
+	switch {
+	case src.BearerToken != "":
+		creds.Header.Set("Authorization", "Bearer "+src.BearerToken)
+	case src.BasicUser != "":
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(src.BasicUser, src.BasicPassword)
+		creds.Header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+	if src.APIKeyParam != "" {
+		creds.Query = url.Values{src.APIKeyParam: {src.APIKey}}
+	}
+	if len(creds.Header) > 0 || len(creds.Query) > 0 {
+		f.Credentials = append(f.Credentials, creds)
+	}
+
+	if src.Proxy != "" {
+		proxyURL, err := url.Parse(src.Proxy)
+		if err != nil {
+			return errors.New("invalid proxy URL")
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		f.Client = &http.Client{Transport: transport}
+	}
+	return nil
+}
+
+// Media returns a JSON Fetcher configured for the MEDIA_URL feed.
+func Media() (*Fetcher, error) {
+	src, err := LoadSource("media")
+	if err != nil {
+		return nil, err
+	}
+	f := JSON()
+	return f, f.Apply(src)
+}
+
+// Stream returns a Fetcher for probing channel streams and fetching logos.
+// It uses the media source's settings, overridden by any "stream" settings.
+// Streams and logos are served by third-party hosts, so the media source's
+// credentials are only sent to the MEDIA_URL host; "stream" credentials go
+// to every host.
+func Stream() (*Fetcher, error) {
+	media, err := LoadSource("media")
+	if err != nil {
+		return nil, err
+	}
+	stream, err := LoadSource("stream")
+	if err != nil {
+		return nil, err
+	}
+	f := New()
+	if err := f.Apply(media); err != nil {
+		return nil, err
+	}
+	if host := mediaHost(); host == "" {
+		f.Credentials = nil
+	} else {
+		for i := range f.Credentials {
+			f.Credentials[i].Host = host
+		}
+	}
+	return f, f.Apply(stream)
+}
+
+// mediaHost returns the host of MEDIA_URL, or "" when it is not an HTTP
+// URL.
+func mediaHost() string {
+	u, err := url.Parse(os.Getenv("MEDIA_URL"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.Host
+}
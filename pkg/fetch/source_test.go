@@ -0,0 +1,176 @@
+package fetch
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func clearSourceEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"MEDIA", "STREAM"} {
+		for _, key := range []string{"USER_AGENT", "REFERER", "COOKIE", "BEARER_TOKEN", "BASIC_USER", "BASIC_PASSWORD", "API_KEY_PARAM", "API_KEY", "PROXY", "HEADERS"} {
+			t.Setenv(name+"_"+key, "")
+		}
+	}
+	t.Setenv("SOURCES_FILE", "")
+}
+
+func TestLoadSourcePrecedence(t *testing.T) {
+	clearSourceEnv(t)
+	path := filepath.Join(t.TempDir(), "sources.json")
+	err := os.WriteFile(path, []byte(`{"media": {"user_agent": "file-agent", "referer": "https://file.example.com/", "headers": {"X-From-File": "1"}}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOURCES_FILE", path)
+	t.Setenv("MEDIA_USER_AGENT", "env-agent")
+	t.Setenv("MEDIA_HEADERS", `{"X-From-Env": "2"}`)
+
+	src, err := LoadSource("media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.UserAgent != "env-agent" || src.Referer != "https://file.example.com/" {
+		t.Errorf("got user agent %q and referer %q, want the env agent over the file's", src.UserAgent, src.Referer)
+	}
+	if src.Headers["X-From-File"] != "1" || src.Headers["X-From-Env"] != "2" {
+		t.Errorf("got headers %v, want both the file's and the env's", src.Headers)
+	}
+
+	if src, err := LoadSource("stream"); err != nil || src.UserAgent != "" {
+		t.Errorf("source missing from the file: got %+v, %v", src, err)
+	}
+
+	t.Setenv("SOURCES_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := LoadSource("media"); err == nil {
+		t.Error("missing SOURCES_FILE: got no error")
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want map[string]string
+	}{
+		{`{"X-Api-Key": "k", "Accept": "application/json"}`, map[string]string{"X-Api-Key": "k", "Accept": "application/json"}},
+		{"X-Api-Key: k\n\nCookie: a=1; b=2\n", map[string]string{"X-Api-Key": "k", "Cookie": "a=1; b=2"}},
+		{"Authorization: Basic dTpwOg==", map[string]string{"Authorization": "Basic dTpwOg=="}},
+	} {
+		got, err := parseHeaders(tt.in)
+		if err != nil || len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, %v", tt.in, got, err)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%q: %s is %q, want %q", tt.in, k, got[k], v)
+			}
+		}
+	}
+
+	for _, bad := range []string{`{"X-Api-Key": "secret"`, "X-Api-Key secret"} {
+		_, err := parseHeaders(bad)
+		if err == nil {
+			t.Errorf("%q: got no error", bad)
+		} else if strings.Contains(err.Error(), "secret") {
+			t.Errorf("%q: error %q includes the value", bad, err)
+		}
+	}
+}
+
+// captured records the credentials of the last request to a server.
+type captured struct {
+	authorization, cookie, apiKey, custom, userAgent string
+}
+
+func recordingServer(t *testing.T, got *captured) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = captured{
+			authorization: r.Header.Get("Authorization"),
+			cookie:        r.Header.Get("Cookie"),
+			apiKey:        r.URL.Query().Get("key"),
+			custom:        r.Header.Get("X-Api-Key"),
+			userAgent:     r.Header.Get("User-Agent"),
+		}
+		w.Write([]byte("#EXTM3U\n"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamCredentialsOnlyForMediaHost(t *testing.T) {
+	clearSourceEnv(t)
+	var atMedia, atForeign captured
+	media := recordingServer(t, &atMedia)
+	// 127.0.0.1 and localhost are different hosts to the fetcher.
+	foreign := recordingServer(t, &atForeign)
+	foreignURL := "http://localhost:" + strconv.Itoa(foreign.Listener.Addr().(*net.TCPAddr).Port)
+
+	t.Setenv("MEDIA_URL", media.URL+"/feed.json")
+	t.Setenv("MEDIA_USER_AGENT", "iptv-agent")
+	t.Setenv("MEDIA_BEARER_TOKEN", "media-token")
+	t.Setenv("MEDIA_COOKIE", "session=media")
+	t.Setenv("MEDIA_API_KEY_PARAM", "key")
+	t.Setenv("MEDIA_API_KEY", "media-key")
+	t.Setenv("MEDIA_HEADERS", "X-Api-Key: media-header")
+
+	f, err := Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get(context.Background(), media.URL+"/live/tvj.m3u8"); err != nil {
+		t.Fatal(err)
+	}
+	if atMedia.authorization != "Bearer media-token" || atMedia.cookie != "session=media" || atMedia.apiKey != "media-key" || atMedia.custom != "media-header" {
+		t.Errorf("MEDIA_URL host: got %+v, want the media credentials", atMedia)
+	}
+
+	if _, err := f.Get(context.Background(), foreignURL+"/logo.png"); err != nil {
+		t.Fatal(err)
+	}
+	if atForeign.authorization != "" || atForeign.cookie != "" || atForeign.apiKey != "" || atForeign.custom != "" {
+		t.Errorf("foreign host: got %+v, want no credentials", atForeign)
+	}
+	if atForeign.userAgent != "iptv-agent" {
+		t.Errorf("foreign host: got user agent %q, want the media user agent", atForeign.userAgent)
+	}
+
+	// Stream credentials are meant for every host.
+	t.Setenv("STREAM_BEARER_TOKEN", "stream-token")
+	if f, err = Stream(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get(context.Background(), foreignURL+"/logo.png"); err != nil {
+		t.Fatal(err)
+	}
+	if atForeign.authorization != "Bearer stream-token" || atForeign.cookie != "" || atForeign.apiKey != "" {
+		t.Errorf("foreign host with STREAM_BEARER_TOKEN: got %+v", atForeign)
+	}
+}
+
+func TestStreamWithoutHTTPMediaURL(t *testing.T) {
+	clearSourceEnv(t)
+	var got captured
+	srv := recordingServer(t, &got)
+	t.Setenv("MEDIA_URL", "testdata/feed.json")
+	t.Setenv("MEDIA_BEARER_TOKEN", "media-token")
+
+	f, err := Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get(context.Background(), srv.URL+"/live/tvj.m3u8"); err != nil {
+		t.Fatal(err)
+	}
+	if got.authorization != "" {
+		t.Errorf("file MEDIA_URL: got Authorization %q on a stream host", got.authorization)
+	}
+}
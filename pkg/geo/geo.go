@@ -0,0 +1,234 @@
+// Package geo resolves the country of the caller.
+//
+// Client headers are only trusted when the platform in front of the app
+// sets them, so GEO_PLATFORM names the deployment:
+//
+//	vercel      X-Vercel-IP-Country and X-Real-IP (the default)
+//	cloudflare  CF-IPCountry and CF-Connecting-IP
+//	proxy       the last X-Forwarded-For address, as added by a trusted
+//	            reverse proxy
+//	direct      the connection's remote address
+//
+// Without a country header the client IP is looked up in the offline
+// database at GEOIP_CSV. Each line of that file is either "network,country"
+// with a CIDR network or "first_ip,last_ip,country"; MMDB files are not
+// supported.
+//
+// Callers whose country cannot be determined are treated as Unknown, which
+// restricted channels do not allow, unless GEO_UNKNOWN=allow.
+package geo
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"template-go-vercel/pkg/logging"
+)
+
+// Platforms understood in GEO_PLATFORM.
+const (
+	Vercel     = "vercel"
+	Cloudflare = "cloudflare"
+	Proxy      = "proxy"
+	Direct     = "direct"
+)
+
+// Unknown is the country of callers whose country cannot be determined
+// when GEO_UNKNOWN does not allow them.
+const Unknown = "XX"
+
+// Enabled reports whether GEO_RESTRICT asks for channels to be filtered by
+// the caller's country.
+func Enabled() bool {
+	return os.Getenv("GEO_RESTRICT") == "1"
+}
+
+// AllowUnknown reports whether GEO_UNKNOWN=allow lets callers of unknown
+// country play country-restricted channels.
+func AllowUnknown() bool {
+	return strings.EqualFold(os.Getenv("GEO_UNKNOWN"), "allow")
+}
+
+// Platform returns GEO_PLATFORM, defaulting to Vercel.
+func Platform() string {
+	if p := strings.ToLower(os.Getenv("GEO_PLATFORM")); p != "" {
+		return p
+	}
+	return Vercel
+}
+
+// countryHeader is the country header set by each platform's edge.
+var countryHeader = map[string]string{
+	Vercel:     "X-Vercel-IP-Country",
+	Cloudflare: "CF-IPCountry",
+}
+
+// Country returns the caller's upper-case country code, or "" when it
+// cannot be determined.
+func Country(r *http.Request) string {
+	if h := countryHeader[Platform()]; h != "" {
+		c := strings.ToUpper(strings.TrimSpace(r.Header.Get(h)))
+		switch c {
+		case "", "XX", "T1":
+			// Missing, unknown or Tor.
+		default:
+			return c
+		}
+	}
+	path := os.Getenv("GEOIP_CSV")
+	if path == "" {
+		return ""
+	}
+	db, err := open(path)
+	if err != nil {
+		logging.From(r.Context()).Error("loading GEOIP_CSV failed", "error", err)
+		return ""
+	}
+	ip, ok := ClientIP(r)
+	if !ok {
+		return ""
+	}
+	return db.Lookup(ip)
+}
+
+// ClientIP returns the client address reported by the GEO_PLATFORM edge,
+// or the connection's remote address when there is none.
+func ClientIP(r *http.Request) (netip.Addr, bool) {
+	var candidate string
+	switch Platform() {
+	case Vercel:
+		candidate = r.Header.Get("X-Real-IP")
+	case Cloudflare:
+		candidate = r.Header.Get("CF-Connecting-IP")
+	case Proxy:
+		// Clients can send their own X-Forwarded-For; only the address
+		// appended by the proxy itself can be trusted.
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			hops := strings.Split(xff[len(xff)-1], ",")
+			candidate = hops[len(hops)-1]
+		}
+	}
+	if ip, err := netip.ParseAddr(strings.TrimSpace(candidate)); err == nil {
+		return ip.Unmap(), true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return ip.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+type ipRange struct {
+	first, last netip.Addr
+	country     string
+}
+
+// DB is an in-memory table of IP ranges sorted by first address.
+type DB struct {
+	ranges []ipRange
+}
+
+var (
+	mu     sync.Mutex
+	loaded = map[string]*DB{}
+)
+
+// open loads path once per process.
+func open(path string) (*DB, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if db, ok := loaded[path]; ok {
+		return db, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := Load(f)
+	if err != nil {
+		return nil, err
+	}
+	loaded[path] = db
+	return db, nil
+}
+
+// Load reads a GeoIP CSV table.
+func Load(r io.Reader) (*DB, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	db := &DB{}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rng ipRange
+		switch len(rec) {
+		case 2:
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(rec[0]))
+			if err != nil {
+				continue // header or malformed line
+			}
+			prefix = prefix.Masked()
+			rng = ipRange{first: prefix.Addr(), last: lastAddr(prefix), country: rec[1]}
+		case 3:
+			first, err1 := netip.ParseAddr(strings.TrimSpace(rec[0]))
+			last, err2 := netip.ParseAddr(strings.TrimSpace(rec[1]))
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			rng = ipRange{first: first.Unmap(), last: last.Unmap(), country: rec[2]}
+		default:
+			return nil, errors.New("geo: expected 2 or 3 columns")
+		}
+		rng.country = strings.ToUpper(strings.TrimSpace(rng.country))
+		db.ranges = append(db.ranges, rng)
+	}
+	sort.Slice(db.ranges, func(i, j int) bool { return db.ranges[i].first.Less(db.ranges[j].first) })
+	return db, nil
+}
+
+// Lookup returns the country of ip, or "".
+func (db *DB) Lookup(ip netip.Addr) string {
+	i := sort.Search(len(db.ranges), func(i int) bool { return ip.Less(db.ranges[i].first) })
+	if i == 0 {
+		return ""
+	}
+	rng := db.ranges[i-1]
+	if rng.last.Less(ip) {
+		return ""
+	}
+	return rng.country
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := range b {
+		bits := p.Bits() - i*8
+		switch {
+		case bits >= 8:
+		case bits <= 0:
+			b[i] = 0xff
+		default:
+			b[i] |= 0xff >> bits
+		}
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
@@ -0,0 +1,66 @@
+package geo
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "geoip.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	db, err := Load(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]string{
+		"203.0.113.7":    "JM",
+		"203.0.114.1":    "",
+		"198.51.100.127": "US",
+		"198.51.100.128": "",
+		"2001:db8::1":    "GB",
+		"192.0.2.1":      "",
+	} {
+		if got := db.Lookup(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("Lookup(%s): got %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestCountry(t *testing.T) {
+	t.Setenv("GEOIP_CSV", filepath.Join("testdata", "geoip.csv"))
+	tests := []struct {
+		platform string
+		headers  map[string]string
+		remote   string
+		want     string
+	}{
+		{platform: "", headers: map[string]string{"X-Vercel-IP-Country": "jm"}, want: "JM"},
+		// A client-sent Cloudflare header is ignored on Vercel, and so is
+		// a spoofed X-Forwarded-For.
+		{platform: "vercel", headers: map[string]string{"CF-IPCountry": "US", "X-Forwarded-For": "198.51.100.1", "X-Real-IP": "203.0.113.9"}, want: "JM"},
+		{platform: "cloudflare", headers: map[string]string{"CF-IPCountry": "US", "X-Vercel-IP-Country": "JM"}, want: "US"},
+		{platform: "cloudflare", headers: map[string]string{"CF-IPCountry": "XX", "CF-Connecting-IP": "203.0.113.9"}, want: "JM"},
+		{platform: "cloudflare", headers: map[string]string{"CF-IPCountry": "T1"}, remote: "192.0.2.1:1234", want: ""},
+		{platform: "proxy", headers: map[string]string{"X-Vercel-IP-Country": "US", "X-Forwarded-For": "198.51.100.1, 203.0.113.9"}, want: "JM"},
+		{platform: "direct", headers: map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Real-IP": "203.0.113.9"}, remote: "198.51.100.1:1234", want: "US"},
+	}
+	for _, tt := range tests {
+		t.Setenv("GEO_PLATFORM", tt.platform)
+		r := httptest.NewRequest("GET", "/M3U", nil)
+		if tt.remote != "" {
+			r.RemoteAddr = tt.remote
+		}
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		if got := Country(r); got != tt.want {
+			t.Errorf("%q with %v: got %q, want %q", tt.platform, tt.headers, got, tt.want)
+		}
+	}
+}
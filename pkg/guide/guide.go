@@ -0,0 +1,140 @@
+// Package guide lays out programmes as a time-by-channel grid for the HTML
+// TV guide. Positions are percentages of the day so the page can be drawn
+// with CSS alone.
+package guide
+
+import (
+	"net/url"
+	"os"
+	"time"
+
+	"template-go-vercel/pkg/source"
+)
+
+// SlotLength is the spacing of the time ruler.
+const SlotLength = 30 * time.Minute
+
+// DayFormat is the format of the day query parameter.
+const DayFormat = "2006-01-02"
+
+// Location returns the time zone named by GUIDE_TZ, or UTC.
+func Location() *time.Location {
+	if name := os.Getenv("GUIDE_TZ"); name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// Slot is a mark on the time ruler.
+type Slot struct {
+	Time   time.Time
+	Offset float64
+}
+
+// Cell is a programme clipped to the day.
+type Cell struct {
+	Programme source.Programme
+	Offset    float64
+	Width     float64
+	// OnAir is set for the programme showing at the grid's current time.
+	OnAir bool
+}
+
+// Row is one channel.
+type Row struct {
+	Channel source.Channel
+	Cells   []Cell
+}
+
+// Grid is one day of programmes.
+type Grid struct {
+	Day        time.Time
+	Start, End time.Time
+	Slots      []Slot
+	Rows       []Row
+	// Now is the offset of the current time, or negative when the grid does
+	// not show today.
+	Now        float64
+	Prev, Next string
+}
+
+// Build lays out channels' programmes for the day containing day, in day's
+// location. now places the current-time marker.
+func Build(channels []source.Channel, day, now time.Time) *Grid {
+	loc := day.Location()
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+	length := float64(end.Sub(start))
+	offset := func(t time.Time) float64 {
+		return 100 * float64(t.Sub(start)) / length
+	}
+
+	g := &Grid{
+		Day:   start,
+		Start: start,
+		End:   end,
+		Now:   -1,
+		Prev:  start.AddDate(0, 0, -1).Format(DayFormat),
+		Next:  end.Format(DayFormat),
+	}
+	for t := start; t.Before(end); t = t.Add(SlotLength) {
+		g.Slots = append(g.Slots, Slot{Time: t, Offset: offset(t)})
+	}
+	onToday := !now.Before(start) && now.Before(end)
+	if onToday {
+		g.Now = offset(now)
+	}
+
+	for _, ch := range channels {
+		row := Row{Channel: ch}
+		for _, p := range ch.Programmes {
+			if !p.End.After(start) || !p.Start.Before(end) {
+				continue
+			}
+			p.Start, p.End = p.Start.In(loc), p.End.In(loc)
+			from, to := p.Start, p.End
+			if from.Before(start) {
+				from = start
+			}
+			if to.After(end) {
+				to = end
+			}
+			row.Cells = append(row.Cells, Cell{
+				Programme: p,
+				Offset:    offset(from),
+				Width:     offset(to) - offset(from),
+				OnAir:     onToday && !now.Before(p.Start) && now.Before(p.End),
+			})
+		}
+		g.Rows = append(g.Rows, row)
+	}
+	return g
+}
+
+// Page is the data of the "guide" page.
+type Page struct {
+	Grid *Grid
+	// Day is the requested day, empty for today.
+	Day string
+	// Print selects the printable list instead of the grid.
+	Print bool
+	// Token is carried over to navigation links of private guides.
+	Token string
+}
+
+// Link returns a relative link to day, in print mode or not.
+func (p *Page) Link(day string, print bool) string {
+	q := url.Values{}
+	if day != "" {
+		q.Set("day", day)
+	}
+	if print {
+		q.Set("print", "1")
+	}
+	if p.Token != "" {
+		q.Set("token", p.Token)
+	}
+	return "?" + q.Encode()
+}
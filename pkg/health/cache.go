@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"time"
+
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/store"
+)
+
+const cacheKey = "health:results"
+
+// DefaultCacheTTL is how long probe results are reused when
+// HEALTH_CACHE_TTL is unset.
+const DefaultCacheTTL = 2 * time.Minute
+
+// CacheTTL returns HEALTH_CACHE_TTL, a Go duration, or DefaultCacheTTL.
+// "0" or "off" disables the cache.
+func CacheTTL() time.Duration {
+	s := os.Getenv("HEALTH_CACHE_TTL")
+	switch s {
+	case "":
+		return DefaultCacheTTL
+	case "0", "off":
+		return 0
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d
+	}
+	return DefaultCacheTTL
+}
+
+// Cache keeps recent probe results in a store so that repeated playlist
+// requests only probe the streams whose result has expired. Results are
+// keyed by a hash of the stream URL, which is not stored, as it may carry
+// credentials.
+type Cache struct {
+	Store store.Store
+	TTL   time.Duration
+	// Now returns the current time; tests may replace it.
+	Now func() time.Time
+}
+
+// NewCache returns a Cache on s with CacheTTL.
+func NewCache(s store.Store) *Cache {
+	return &Cache{Store: s, TTL: CacheTTL()}
+}
+
+func (k *Cache) now() time.Time {
+	if k.Now != nil {
+		return k.Now()
+	}
+	return time.Now()
+}
+
+func urlKey(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:16])
+}
+
+// CheckAll returns the cached result of every target probed within TTL
+// and probes the others with c, in the same order as targets. Store
+// errors are logged and only cost the caching.
+func (k *Cache) CheckAll(ctx context.Context, c *Checker, targets []Target) []Result {
+	if k.TTL <= 0 {
+		return c.CheckAll(ctx, targets)
+	}
+	now := k.now()
+	fresh := func(r Result) bool {
+		return now.Sub(r.CheckedAt) < k.TTL
+	}
+
+	cached := map[string]Result{}
+	b, err := k.Store.Get(ctx, cacheKey)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, &cached); err != nil {
+			logging.From(ctx).Warn("decoding stream health cache failed", "error", err)
+			cached = map[string]Result{}
+		}
+	case err != store.ErrNotFound:
+		logging.From(ctx).Warn("loading stream health cache failed", "error", err)
+	}
+
+	results := make([]Result, len(targets))
+	var missing []Target
+	var missingAt []int
+	for i, t := range targets {
+		if r, ok := cached[urlKey(t.URL)]; ok && t.URL != "" && fresh(r) {
+			r.ID, r.Name, r.URL = t.ID, t.Name, t.URL
+			results[i] = r
+			continue
+		}
+		missing = append(missing, t)
+		missingAt = append(missingAt, i)
+	}
+	if len(missing) == 0 {
+		return results
+	}
+
+	probed := c.CheckAll(ctx, missing)
+	for j, r := range probed {
+		results[missingAt[j]] = r
+	}
+	if ctx.Err() != nil {
+		// Probes cut short by the request say nothing about the streams.
+		return results
+	}
+
+	err = k.Store.Update(ctx, cacheKey, func(old []byte) ([]byte, error) {
+		entries := map[string]Result{}
+		if old != nil {
+			json.Unmarshal(old, &entries)
+		}
+		for key, r := range entries {
+			if !fresh(r) {
+				delete(entries, key)
+			}
+		}
+		for j, r := range probed {
+			if missing[j].URL == "" {
+				continue
+			}
+			r.ID, r.Name, r.URL = "", "", ""
+			entries[urlKey(missing[j].URL)] = r
+		}
+		return json.Marshal(entries)
+	})
+	if err != nil {
+		logging.From(ctx).Warn("saving stream health cache failed", "error", err)
+	}
+	return results
+}
@@ -0,0 +1,193 @@
+// Package health probes HLS stream URLs and reports whether they are playable.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+)
+
+// Status is the outcome of a stream probe.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Target is a single stream to probe.
+type Target struct {
+	ID   string
+	Name string
+	URL  string
+}
+
+// Result records the status and latency of one probe.
+type Result struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url,omitempty"`
+	Status     Status    `json:"status"`
+	HTTPStatus int       `json:"http_status,omitempty"`
+	Segments   int       `json:"segments"`
+	LatencyMS  int64     `json:"latency_ms"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Up reports whether the stream answered with a playable manifest.
+func (r Result) Up() bool {
+	return r.Status == StatusUp
+}
+
+// Checker probes streams with bounded concurrency and per-probe timeouts.
+type Checker struct {
+	Fetcher     *fetch.Fetcher
+	Timeout     time.Duration
+	Concurrency int
+}
+
+// NewChecker returns a Checker configured from HEALTH_TIMEOUT (a Go
+// duration) and HEALTH_CONCURRENCY, falling back to sane defaults.
+// Manifests are fetched once, without retries, using the "stream" source
+// settings from package fetch.
+func NewChecker() (*Checker, error) {
+	f, err := fetch.Stream()
+	if err != nil {
+		return nil, err
+	}
+	c := &Checker{
+		Fetcher:     f,
+		Timeout:     5 * time.Second,
+		Concurrency: 10,
+	}
+	if d, err := time.ParseDuration(os.Getenv("HEALTH_TIMEOUT")); err == nil && d > 0 {
+		c.Timeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("HEALTH_CONCURRENCY")); err == nil && n > 0 {
+		c.Concurrency = n
+	}
+	f.Timeout = c.Timeout
+	f.MaxAttempts = 1
+	f.MaxBodyBytes = 1 << 20
+	return c, nil
+}
+
+// Check probes a single target. The manifest must parse as HLS and, for
+// master playlists, the first variant must list at least one segment.
+func (c *Checker) Check(ctx context.Context, t Target) (res Result) {
+	res = Result{ID: t.ID, Name: t.Name, URL: t.URL, Status: StatusDown}
+	start := time.Now()
+	defer func() {
+		res.CheckedAt = start.UTC()
+		res.LatencyMS = time.Since(start).Milliseconds()
+	}()
+
+	if t.URL == "" {
+		res.Error = "no stream url"
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	m, status, err := c.fetchManifest(ctx, t.URL)
+	res.HTTPStatus = status
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if m.Segments == 0 && len(m.Variants) > 0 {
+		m, _, err = c.fetchManifest(ctx, m.Variants[0])
+		if err != nil {
+			res.Error = fmt.Sprintf("variant: %v", err)
+			return res
+		}
+	}
+	res.Segments = m.Segments
+	if m.Segments == 0 {
+		res.Error = "playlist has no segments"
+		return res
+	}
+	res.Status = StatusUp
+	return res
+}
+
+// CheckAll probes every target, at most Concurrency at a time. Results are
+// returned in the same order as targets.
+func (c *Checker) CheckAll(ctx context.Context, targets []Target) []Result {
+	results := make([]Result, len(targets))
+	limit := c.Concurrency
+	if limit <= 0 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, t Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = c.Check(ctx, t)
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
+
+func (c *Checker) fetchManifest(ctx context.Context, rawURL string) (*manifest, int, error) {
+	// Errors leave out the URL, which is reported separately and only to
+	// admins, as it may carry credentials.
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, 0, errors.New("invalid stream url")
+	}
+	body, err := c.Fetcher.Get(ctx, rawURL)
+	if err != nil {
+		var se *fetch.StatusError
+		if errors.As(err, &se) {
+			return nil, se.StatusCode, fmt.Errorf("unexpected status %d", se.StatusCode)
+		}
+		if fetch.IsTimeout(err) {
+			return nil, 0, fmt.Errorf("timed out after %s", c.Timeout)
+		}
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return nil, 0, ue.Err
+		}
+		return nil, 0, errors.New(fetch.Message(err))
+	}
+	m, err := parseManifest(base, body)
+	return m, http.StatusOK, err
+}
+
+// Report summarises a set of probe results.
+type Report struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Total     int       `json:"total"`
+	Up        int       `json:"up"`
+	Down      int       `json:"down"`
+	Results   []Result  `json:"results"`
+}
+
+// NewReport builds a Report from results.
+func NewReport(results []Result) Report {
+	r := Report{CheckedAt: time.Now().UTC(), Total: len(results), Results: results}
+	for _, res := range results {
+		if res.Up() {
+			r.Up++
+		} else {
+			r.Down++
+		}
+	}
+	return r
+}
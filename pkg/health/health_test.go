@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/store"
+)
+
+const (
+	masterPlaylist = "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=2000000\n/hd/index.m3u8\n"
+	mediaPlaylist  = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.0,\nseg1.ts\n#EXTINF:6.0,\nseg2.ts\n"
+)
+
+func TestParseManifest(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/live/tvj/master.m3u8")
+
+	m, err := parseManifest(base, []byte(masterPlaylist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://cdn.example.com/live/tvj/low/index.m3u8", "https://cdn.example.com/hd/index.m3u8"}
+	if m.Segments != 0 || len(m.Variants) != 2 || m.Variants[0] != want[0] || m.Variants[1] != want[1] {
+		t.Errorf("master playlist: got %+v, want variants %q", m, want)
+	}
+
+	m, err = parseManifest(base, []byte(mediaPlaylist))
+	if err != nil || m.Segments != 2 || len(m.Variants) != 0 {
+		t.Errorf("media playlist: got %+v, %v", m, err)
+	}
+
+	for _, body := range []string{"", "<html></html>", "seg1.ts\n#EXTM3U\n"} {
+		if _, err := parseManifest(base, []byte(body)); err != ErrNotPlaylist {
+			t.Errorf("%q: got %v, want ErrNotPlaylist", body, err)
+		}
+	}
+}
+
+func newTestChecker(timeout time.Duration, concurrency int) *Checker {
+	f := fetch.New()
+	f.MaxAttempts = 1
+	f.Timeout = timeout
+	return &Checker{Fetcher: f, Timeout: timeout, Concurrency: concurrency}
+}
+
+func TestCheckMasterPlaylist(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			w.Write([]byte(masterPlaylist))
+		case "/low/index.m3u8":
+			w.Write([]byte(mediaPlaylist))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestChecker(time.Second, 2)
+	res := c.Check(context.Background(), Target{ID: "tvj", URL: srv.URL + "/master.m3u8"})
+	if !res.Up() || res.Segments != 2 {
+		t.Errorf("master playlist: got %+v", res)
+	}
+	res = c.Check(context.Background(), Target{ID: "cvm", URL: srv.URL + "/missing.m3u8"})
+	if res.Up() || res.HTTPStatus != http.StatusNotFound {
+		t.Errorf("missing playlist: got %+v", res)
+	}
+}
+
+func TestCheckAllConcurrencyAndTimeout(t *testing.T) {
+	var active, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		if r.URL.Path == "/slow.m3u8" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(mediaPlaylist))
+	}))
+	defer srv.Close()
+
+	targets := []Target{{ID: "slow", URL: srv.URL + "/slow.m3u8"}}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f"} {
+		targets = append(targets, Target{ID: id, URL: srv.URL + "/" + id + ".m3u8"})
+	}
+	start := time.Now()
+	results := newTestChecker(100*time.Millisecond, 2).CheckAll(context.Background(), targets)
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("CheckAll took %s, the slow stream was not cut off", elapsed)
+	}
+	if peak := atomic.LoadInt32(&peak); peak > 2 {
+		t.Errorf("%d probes ran at once, want at most 2", peak)
+	}
+	for i, res := range results {
+		if res.ID != targets[i].ID {
+			t.Errorf("result %d: got %s, want %s", i, res.ID, targets[i].ID)
+		}
+	}
+	if results[0].Up() || !strings.Contains(results[0].Error, "timed out") {
+		t.Errorf("slow stream: got %+v", results[0])
+	}
+	for _, res := range results[1:] {
+		if !res.Up() {
+			t.Errorf("%s: got %+v", res.ID, res)
+		}
+	}
+}
+
+func TestCacheCheckAll(t *testing.T) {
+	var mu sync.Mutex
+	probes := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		probes[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte(mediaPlaylist))
+	}))
+	defer srv.Close()
+
+	s := store.NewMemory()
+	now := time.Now()
+	cache := &Cache{Store: s, TTL: time.Minute, Now: func() time.Time { return now }}
+	c := newTestChecker(time.Second, 2)
+	targets := []Target{{ID: "tvj", URL: srv.URL + "/tvj.m3u8"}, {ID: "cvm", URL: srv.URL + "/cvm.m3u8"}}
+
+	cache.CheckAll(context.Background(), c, targets)
+	results := cache.CheckAll(context.Background(), c, targets)
+	if probes["/tvj.m3u8"] != 1 || probes["/cvm.m3u8"] != 1 {
+		t.Errorf("within the TTL: got probes %v, want one per stream", probes)
+	}
+	if results[0].ID != "tvj" || results[0].URL != targets[0].URL || !results[0].Up() {
+		t.Errorf("cached result: got %+v", results[0])
+	}
+	if b, _ := s.Get(context.Background(), cacheKey); strings.Contains(string(b), srv.URL) {
+		t.Errorf("cache stores stream URLs: %s", b)
+	}
+
+	now = now.Add(2 * time.Minute)
+	cache.CheckAll(context.Background(), c, targets[:1])
+	if probes["/tvj.m3u8"] != 2 {
+		t.Errorf("after the TTL: got %d probes of tvj, want 2", probes["/tvj.m3u8"])
+	}
+}
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrNotPlaylist is returned when a manifest does not start with #EXTM3U.
+var ErrNotPlaylist = errors.New("manifest is not an HLS playlist")
+
+// manifest is the subset of an HLS playlist the checker cares about.
+type manifest struct {
+	Variants []string
+	Segments int
+}
+
+// parseManifest reads an HLS media or master playlist. Variant URIs are
+// resolved against base so they can be fetched directly.
+func parseManifest(base *url.URL, body []byte) (*manifest, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	m := &manifest{}
+	header := false
+	expectVariant, expectSegment := false, false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !header {
+			if !strings.HasPrefix(line, "#EXTM3U") {
+				return nil, ErrNotPlaylist
+			}
+			header = true
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+			expectVariant = true
+		case strings.HasPrefix(line, "#EXTINF"):
+			expectSegment = true
+		case strings.HasPrefix(line, "#"):
+			// other tags and comments
+		case expectVariant:
+			expectVariant = false
+			ref, err := url.Parse(line)
+			if err != nil {
+				continue
+			}
+			if base != nil {
+				ref = base.ResolveReference(ref)
+			}
+			m.Variants = append(m.Variants, ref.String())
+		case expectSegment:
+			expectSegment = false
+			m.Segments++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, ErrNotPlaylist
+	}
+	return m, nil
+}
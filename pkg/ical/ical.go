@@ -0,0 +1,127 @@
+// Package ical writes programme schedules as RFC 5545 calendars.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"template-go-vercel/pkg/source"
+)
+
+// ContentType is the media type of calendars.
+const ContentType = "text/calendar; charset=utf-8"
+
+// timeFormat is the UTC DATE-TIME form.
+const timeFormat = "20060102T150405Z"
+
+// uidDomain qualifies event UIDs. It is fixed rather than taken from the
+// request's host, so an event keeps its UID across aliases and previews.
+const uidDomain = "epg.template-go-vercel"
+
+// maxLine is the longest content line, in octets, before it is folded.
+const maxLine = 75
+
+// Calendar is a channel's schedule.
+type Calendar struct {
+	Channel source.Channel
+	// Now is written as each event's DTSTAMP.
+	Now time.Time
+}
+
+// UID identifies the programme starting at start on channelID. It only
+// depends on those two values, so a calendar client updates an event in
+// place when its title or end time changes.
+func UID(channelID string, start time.Time) string {
+	return fmt.Sprintf("%s-%s@%s", channelID, start.UTC().Format(timeFormat), uidDomain)
+}
+
+// Filter returns the programmes whose title contains title, ignoring case.
+// An empty title keeps every programme.
+func Filter(programmes []source.Programme, title string) []source.Programme {
+	if title == "" {
+		return programmes
+	}
+	title = strings.ToLower(title)
+	var out []source.Programme
+	for _, p := range programmes {
+		if strings.Contains(strings.ToLower(p.Title), title) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Marshal encodes c with one VEVENT per programme.
+func (c *Calendar) Marshal() []byte {
+	var b bytes.Buffer
+	line := func(name, value string) { writeLine(&b, name+":"+value) }
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//template-go-vercel//EPG//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", Escape(c.Channel.Name))
+	line("NAME", Escape(c.Channel.Name))
+	line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	line("X-PUBLISHED-TTL", "PT1H")
+	for _, p := range c.Channel.Programmes {
+		line("BEGIN", "VEVENT")
+		line("UID", UID(c.Channel.ID, p.Start))
+		line("DTSTAMP", c.Now.UTC().Format(timeFormat))
+		line("DTSTART", p.Start.UTC().Format(timeFormat))
+		line("DTEND", p.End.UTC().Format(timeFormat))
+		line("SUMMARY", Escape(p.Title))
+		if desc := description(p); desc != "" {
+			line("DESCRIPTION", Escape(desc))
+		}
+		line("LOCATION", Escape(c.Channel.Name))
+		line("CATEGORIES", Escape(c.Channel.GroupName()))
+		if p.Image != "" {
+			line("IMAGE;VALUE=URI", p.Image)
+		}
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return b.Bytes()
+}
+
+func description(p source.Programme) string {
+	desc := p.Description
+	if p.Rating != "" {
+		if desc != "" {
+			desc += "\n\n"
+		}
+		desc += "Rated " + p.Rating
+	}
+	return desc
+}
+
+// Escape escapes a TEXT value.
+func Escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return r.Replace(s)
+}
+
+// writeLine writes s folded into lines of at most maxLine octets, without
+// splitting UTF-8 sequences, each ending in CRLF.
+func writeLine(b *bytes.Buffer, s string) {
+	limit := maxLine
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts.
+		limit = maxLine - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,111 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"template-go-vercel/pkg/source"
+)
+
+// unfold reverses RFC 5545 line folding.
+func unfold(s string) string {
+	return strings.ReplaceAll(s, "\r\n ", "")
+}
+
+func TestWriteLineFolds(t *testing.T) {
+	for _, s := range []string{
+		"SUMMARY:short",
+		"SUMMARY:" + strings.Repeat("a", 67),  // exactly 75 octets
+		"SUMMARY:" + strings.Repeat("a", 68),  // one octet over
+		"SUMMARY:" + strings.Repeat("a", 300), // several continuation lines
+		"SUMMARY:" + strings.Repeat("é", 100), // 2-octet runes
+		"SUMMARY:" + strings.Repeat("🎬", 60),  // 4-octet runes
+		"SUMMARY:a" + strings.Repeat("日本", 40),
+	} {
+		var b bytes.Buffer
+		writeLine(&b, s)
+		out := b.String()
+		if !strings.HasSuffix(out, "\r\n") || strings.Contains(strings.TrimSuffix(out, "\r\n"), "\r\n\r\n") {
+			t.Errorf("%q: bad line endings in %q", s, out)
+		}
+		lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+		for i, l := range lines {
+			if len(l) > maxLine {
+				t.Errorf("%q: line %d is %d octets", s, i, len(l))
+			}
+			if i > 0 && !strings.HasPrefix(l, " ") {
+				t.Errorf("%q: continuation line %d does not start with a space", s, i)
+			}
+			if !utf8.ValidString(l) {
+				t.Errorf("%q: line %d splits a UTF-8 sequence: %q", s, i, l)
+			}
+		}
+		if len(s) <= maxLine && len(lines) != 1 {
+			t.Errorf("%q: folded a line of %d octets", s, len(s))
+		}
+		if got := unfold(strings.TrimSuffix(out, "\r\n")); got != s {
+			t.Errorf("%q: unfolds to %q", s, got)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for in, want := range map[string]string{
+		"News":                 "News",
+		"News, Sport; Weather": `News\, Sport\; Weather`,
+		`C:\path`:              `C:\\path`,
+		"one\ntwo":             `one\ntwo`,
+		"one\r\ntwo\rthree":    `one\ntwo\nthree`,
+		`\,`:                   `\\\,`,
+		"café: «live»":         "café: «live»",
+	} {
+		if got := Escape(in); got != want {
+			t.Errorf("Escape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUID(t *testing.T) {
+	start := time.Date(2024, 5, 1, 20, 0, 0, 0, time.FixedZone("EST", -5*3600))
+	want := "tvj-20240502T010000Z@" + uidDomain
+	if got := UID("tvj", start); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if UID("tvj", start) != UID("tvj", start.UTC()) {
+		t.Error("UID depends on the time zone of start")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	c := &Calendar{
+		Channel: source.Channel{ID: "tvj", Name: "TVJ", Programmes: []source.Programme{{
+			Title:       "News, Sport; Weather",
+			Description: "Headlines from " + strings.Repeat("Kingston, ", 10),
+			Rating:      "PG",
+			Start:       start,
+			End:         start.Add(time.Hour),
+		}}},
+		Now: start,
+	}
+	out := string(c.Marshal())
+	for _, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(l) > maxLine {
+			t.Errorf("line of %d octets: %q", len(l), l)
+		}
+	}
+	body := unfold(out)
+	for _, want := range []string{
+		"UID:tvj-20240501T180000Z@" + uidDomain + "\r\n",
+		"DTSTART:20240501T180000Z\r\nDTEND:20240501T190000Z\r\n",
+		`SUMMARY:News\, Sport\; Weather` + "\r\n",
+		`\n\nRated PG` + "\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("calendar is missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package logging configures structured logging for the handlers.
+//
+// Importing the package installs a log/slog default logger that writes
+// JSON on Vercel (or with LOG_FORMAT=json) and text otherwise, at
+// LOG_LEVEL (debug, info, warn or error; default info). Every record passes
+// through Redact, so secrets in URLs, headers and error messages never
+// reach the log drain.
+//
+// Handlers call Begin to get a request-scoped logger carrying the request
+// ID and handler name, and a completion log line with status and latency.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	slog.SetDefault(New())
+}
+
+// New returns a logger configured from the environment.
+func New() *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level(), ReplaceAttr: replaceAttr}
+	var h slog.Handler
+	if format := strings.ToLower(os.Getenv("LOG_FORMAT")); format == "json" || (format == "" && os.Getenv("VERCEL") != "") {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		h = slog.NewTextHandler(os.Stdout, opts)
+	}
+	return slog.New(h)
+}
+
+func level() slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+type ctxKey struct{}
+
+// With returns a copy of ctx carrying logger.
+func With(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// From returns the logger carried by ctx, or the default logger.
+func From(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+// RequestID returns the request's ID: an incoming X-Request-Id, Vercel's
+// X-Vercel-Id, or a new random one.
+func RequestID(r *http.Request) string {
+	for _, h := range []string{"X-Request-Id", "X-Vercel-Id"} {
+		if id := r.Header.Get(h); id != "" && len(id) <= 128 {
+			return id
+		}
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// StatusRecorder records the status and size of a response.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int
+}
+
+func (s *StatusRecorder) WriteHeader(code int) {
+	if s.Status == 0 {
+		s.Status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *StatusRecorder) Write(b []byte) (int, error) {
+	if s.Status == 0 {
+		s.Status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.Bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *StatusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Begin starts logging a request to the named handler. It sets the
+// X-Request-Id response header and returns the writer and request to use
+// from then on, whose context carries the request logger, and a function
+// to defer that logs the outcome.
+func Begin(w http.ResponseWriter, r *http.Request, name string) (http.ResponseWriter, *http.Request, func()) {
+	start := time.Now()
+	id := RequestID(r)
+	w.Header().Set("X-Request-Id", id)
+	logger := slog.Default().With("request_id", id, "handler", name)
+	rec := &StatusRecorder{ResponseWriter: w}
+	r = r.WithContext(With(r.Context(), logger))
+
+	return rec, r, func() {
+		status := rec.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		lvl := slog.LevelInfo
+		switch {
+		case status >= 500:
+			lvl = slog.LevelError
+		case status >= 400:
+			lvl = slog.LevelWarn
+		}
+		logger.LogAttrs(r.Context(), lvl, "request",
+			slog.String("method", r.Method),
+			slog.String("url", r.URL.String()),
+			slog.Int("status", status),
+			slog.Int("bytes", rec.Bytes),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	}
+}
@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values.
+const Redacted = "[REDACTED]"
+
+// secretParams are query parameters whose values are secrets.
+var secretParams = map[string]bool{
+	"apikey": true, "api_key": true, "key": true, "appid": true,
+	"token": true, "access_token": true, "auth": true,
+	"password": true, "pass": true, "secret": true,
+	"sig": true, "signature": true, "x-amz-signature": true,
+}
+
+// secretKeys are attribute keys and header names whose values are secrets.
+var secretKeys = map[string]bool{
+	"authorization": true, "proxy-authorization": true,
+	"cookie": true, "set-cookie": true, "x-api-key": true,
+	"password": true, "secret": true, "token": true, "api_key": true,
+}
+
+// secretPaths match URL paths that embed credentials: Xtream stream paths
+// (/live/{user}/{pass}/) and private playlist paths (/t/{token}/).
+var secretPaths = []*regexp.Regexp{
+	regexp.MustCompile(`^(/live/)[^/]+/[^/]+(/|$)`),
+	regexp.MustCompile(`^(/t/)[^/]+(/|$)`),
+}
+
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// RedactURL hides passwords, secret query parameters and credentials in
+// the path of raw, which may also be a path with a query string.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+		}
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		changed := false
+		for k := range q {
+			if secretParams[strings.ToLower(k)] {
+				q.Set(k, Redacted)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	for _, re := range secretPaths {
+		if re.MatchString(u.Path) {
+			u.Path = re.ReplaceAllString(u.Path, "${1}"+Redacted+"${2}")
+			u.RawPath = ""
+		}
+	}
+	// Keep the marker readable rather than percent-encoded.
+	return strings.ReplaceAll(u.String(), url.QueryEscape(Redacted), Redacted)
+}
+
+// Redact hides secrets in every URL found in s.
+func Redact(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	return urlPattern.ReplaceAllStringFunc(s, RedactURL)
+}
+
+// RedactHeader returns h as a flat map with secret headers hidden.
+func RedactHeader(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if secretKeys[strings.ToLower(k)] {
+			out[k] = Redacted
+			continue
+		}
+		out[k] = Redact(strings.Join(v, ", "))
+	}
+	return out
+}
+
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	if secretKeys[key] {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		s := a.Value.String()
+		if key == "url" || key == "path" {
+			return slog.String(a.Key, RedactURL(s))
+		}
+		if r := Redact(s); r != s {
+			return slog.String(a.Key, r)
+		}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,244 @@
+// Package logo picks, fetches, caches and normalizes channel logos so that
+// playlists can point clients at /api/logo/{channelId} instead of the
+// upstream CDN.
+//
+// Only PNG, JPEG and GIF images, which the standard library decodes, are
+// served. Anything else, SVG and HTML in particular, would run as active
+// content on this site's origin.
+// Cached logos expire after LOGO_CACHE_TTL (a Go duration, default 24h).
+package logo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+)
+
+// MaxSize is the largest square size that can be requested.
+const MaxSize = 1024
+
+// MaxDimension is the largest width or height of an upstream logo. Larger
+// images are refused before they are decoded.
+const MaxDimension = 4096
+
+// DefaultCacheTTL is how long logos are cached without LOGO_CACHE_TTL.
+const DefaultCacheTTL = 24 * time.Hour
+
+var (
+	// ErrUnsupported is returned for logos that are not PNG, JPEG or GIF
+	// images.
+	ErrUnsupported = errors.New("logo: unsupported image format")
+	// ErrTooLarge is returned for logos larger than MaxDimension.
+	ErrTooLarge = fmt.Errorf("logo: image larger than %dx%d", MaxDimension, MaxDimension)
+)
+
+// formats maps the image.DecodeConfig format names that are served to
+// their media types.
+var formats = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"gif":  "image/gif",
+}
+
+// Status returns the HTTP status for an error from Load.
+func Status(err error) int {
+	if errors.Is(err, ErrUnsupported) || errors.Is(err, ErrTooLarge) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusNotFound
+}
+
+// Message describes an error from Load for clients, without the upstream
+// URLs that fetch errors carry.
+func Message(err error) string {
+	var fe *fetch.Error
+	if errors.As(err, &fe) {
+		return "Error loading logo: " + fetch.Message(err)
+	}
+	return err.Error()
+}
+
+// Candidates returns the logo URLs of ch, best first, without duplicates.
+func Candidates(ch source.Channel) []string {
+	seen := map[string]bool{}
+	var urls []string
+	for _, u := range append(append([]string{}, ch.Logos...), ch.Logo) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Enabled reports whether playlists should reference the logo proxy, either
+// because LOGO_PROXY is set or because the request asked for it.
+func Enabled(query url.Values) bool {
+	return os.Getenv("LOGO_PROXY") == "1" || query.Get("logos") == "proxy"
+}
+
+// DefaultSize returns LOGO_SIZE, the square size used when rewriting
+// playlists, or 0 to keep the original image.
+func DefaultSize() int {
+	n, _ := strconv.Atoi(os.Getenv("LOGO_SIZE"))
+	if n < 0 || n > MaxSize {
+		return 0
+	}
+	return n
+}
+
+// Rewrite points every channel that has a logo at the proxy on baseURL.
+func Rewrite(channels []source.Channel, baseURL string, size int) {
+	for i := range channels {
+		ch := &channels[i]
+		if len(Candidates(*ch)) == 0 {
+			continue
+		}
+		u := baseURL + "/api/logo/" + url.PathEscape(ch.ID)
+		if size > 0 {
+			u += "?size=" + strconv.Itoa(size)
+		}
+		ch.Logo = u
+	}
+}
+
+// Image is a logo ready to be served.
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+// Proxy fetches logos and caches the results in a store.
+type Proxy struct {
+	Fetcher *fetch.Fetcher
+	Store   store.Store
+	// TTL is how long cached logos are served; zero means DefaultCacheTTL.
+	TTL time.Duration
+	// Now returns the current time; nil means time.Now.
+	Now func() time.Time
+}
+
+// CacheTTL returns LOGO_CACHE_TTL, or DefaultCacheTTL when it is unset or
+// invalid.
+func CacheTTL() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("LOGO_CACHE_TTL"))
+	if err != nil || d <= 0 {
+		return DefaultCacheTTL
+	}
+	return d
+}
+
+// cached is the stored form of a logo.
+type cached struct {
+	Data      []byte    `json:"data"`
+	Type      string    `json:"type"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+func cacheKey(channelID string, size int) string {
+	return fmt.Sprintf("logo:%s:%d", channelID, size)
+}
+
+func (p *Proxy) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+func (p *Proxy) ttl() time.Duration {
+	if p.TTL > 0 {
+		return p.TTL
+	}
+	return DefaultCacheTTL
+}
+
+// Cached returns a previously stored logo, or store.ErrNotFound when there
+// is none or it has expired.
+func (p *Proxy) Cached(ctx context.Context, channelID string, size int) (*Image, error) {
+	b, err := p.Store.Get(ctx, cacheKey(channelID, size))
+	if err != nil {
+		return nil, err
+	}
+	var c cached
+	if err := json.Unmarshal(b, &c); err != nil || c.Type == "" || p.now().Sub(c.FetchedAt) > p.ttl() {
+		return nil, store.ErrNotFound
+	}
+	return &Image{Data: c.Data, ContentType: c.Type}, nil
+}
+
+// Load fetches the first candidate that is a supported image no larger
+// than MaxDimension and, when size is positive, scales and pads it to a
+// size x size PNG. The result is cached.
+func (p *Proxy) Load(ctx context.Context, ch source.Channel, size int) (*Image, error) {
+	var lastErr error
+	for _, u := range Candidates(ch) {
+		b, err := p.Fetcher.Get(ctx, u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		format, err := check(b)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if size <= 0 {
+			return p.save(ctx, ch.ID, size, &Image{Data: b, ContentType: formats[format]}), nil
+		}
+		img, _, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			lastErr = ErrUnsupported
+			continue
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, Square(img, size)); err != nil {
+			return nil, err
+		}
+		return p.save(ctx, ch.ID, size, &Image{Data: buf.Bytes(), ContentType: formats["png"]}), nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("channel %s has no logo", ch.ID)
+	}
+	return nil, lastErr
+}
+
+// check reads only the image header and returns the format of b, or an
+// error when it is not served or too large to decode.
+func check(b []byte) (string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil || formats[format] == "" {
+		return "", ErrUnsupported
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension {
+		return "", ErrTooLarge
+	}
+	return format, nil
+}
+
+func (p *Proxy) save(ctx context.Context, channelID string, size int, img *Image) *Image {
+	b, err := json.Marshal(cached{Data: img.Data, Type: img.ContentType, FetchedAt: p.now()})
+	if err == nil {
+		err = p.Store.Set(ctx, cacheKey(channelID, size), b)
+	}
+	if err != nil {
+		logging.From(ctx).Warn("caching logo failed", "error", err)
+	}
+	return img
+}
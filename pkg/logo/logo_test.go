@@ -0,0 +1,125 @@
+package logo
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+)
+
+func pngOf(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// upstream serves each body at its path, with a misleading content type.
+func upstream(t *testing.T, bodies map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLoad(t *testing.T) {
+	srv := upstream(t, map[string][]byte{
+		"/logo.png":  pngOf(t, 40, 20),
+		"/logo.svg":  []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"></svg>`),
+		"/page":      []byte(`<html><script>alert(1)</script></html>`),
+		"/logo.webp": []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00"),
+		// A valid header claiming a huge image, without the pixel data.
+		"/huge.png": pngOf(t, MaxDimension+1, 1)[:33],
+	})
+	p := &Proxy{Fetcher: fetch.New(), Store: store.NewMemory()}
+	ctx := context.Background()
+
+	tests := []struct {
+		logo    string
+		size    int
+		want    error
+		wantTyp string
+	}{
+		{logo: "/logo.png", wantTyp: "image/png"},
+		{logo: "/logo.png", size: 16, wantTyp: "image/png"},
+		{logo: "/logo.svg", want: ErrUnsupported},
+		{logo: "/page", want: ErrUnsupported},
+		{logo: "/logo.webp", want: ErrUnsupported},
+		{logo: "/huge.png", size: 16, want: ErrTooLarge},
+	}
+	for _, tt := range tests {
+		img, err := p.Load(ctx, source.Channel{ID: tt.logo, Logo: srv.URL + tt.logo}, tt.size)
+		if err != tt.want {
+			t.Errorf("%s: got error %v, want %v", tt.logo, err, tt.want)
+			continue
+		}
+		if err != nil {
+			if Status(err) != http.StatusUnsupportedMediaType {
+				t.Errorf("%s: got status %d, want 415", tt.logo, Status(err))
+			}
+			continue
+		}
+		if img.ContentType != tt.wantTyp {
+			t.Errorf("%s: got %s, want %s", tt.logo, img.ContentType, tt.wantTyp)
+		}
+		if tt.size > 0 {
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+			if err != nil || cfg.Width != tt.size || cfg.Height != tt.size {
+				t.Errorf("%s: got %dx%d (%v), want %dx%d", tt.logo, cfg.Width, cfg.Height, err, tt.size, tt.size)
+			}
+		}
+	}
+}
+
+func TestLoadFallsBackToNextCandidate(t *testing.T) {
+	srv := upstream(t, map[string][]byte{
+		"/logo.svg": []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`),
+		"/logo.png": pngOf(t, 8, 8),
+	})
+	p := &Proxy{Fetcher: fetch.New(), Store: store.NewMemory()}
+	ch := source.Channel{ID: "tvj", Logos: []string{srv.URL + "/logo.svg"}, Logo: srv.URL + "/logo.png"}
+	img, err := p.Load(context.Background(), ch, 0)
+	if err != nil || img.ContentType != "image/png" {
+		t.Fatalf("got %v, %v; want the PNG", img, err)
+	}
+}
+
+func TestCachedExpires(t *testing.T) {
+	srv := upstream(t, map[string][]byte{"/logo.png": pngOf(t, 8, 8)})
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	p := &Proxy{Fetcher: fetch.New(), Store: store.NewMemory(), TTL: time.Hour, Now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	if _, err := p.Cached(ctx, "tvj", 0); err != store.ErrNotFound {
+		t.Fatalf("empty cache: got %v, want ErrNotFound", err)
+	}
+	if _, err := p.Load(ctx, source.Channel{ID: "tvj", Logo: srv.URL + "/logo.png"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(59 * time.Minute)
+	if img, err := p.Cached(ctx, "tvj", 0); err != nil || img.ContentType != "image/png" {
+		t.Errorf("within TTL: got %v, %v", img, err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := p.Cached(ctx, "tvj", 0); err != store.ErrNotFound {
+		t.Errorf("after TTL: got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,72 @@
+package logo
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Square scales img to fit inside a size x size square, preserving its
+// aspect ratio, and centres it on a transparent background.
+func Square(img image.Image, size int) *image.RGBA {
+	src := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+
+	w, h := size, size
+	if sw > sh {
+		h = max1(sh * size / sw)
+	} else {
+		w = max1(sw * size / sh)
+	}
+	scaled := scale(src, w, h)
+	offset := image.Pt((size-w)/2, (size-h)/2)
+	draw.Draw(dst, scaled.Bounds().Add(offset), scaled, image.Point{}, draw.Over)
+	return dst
+}
+
+// scale resizes src to w x h by averaging the source pixels covered by
+// each destination pixel, which also works for enlarging.
+func scale(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := max1((y + 1) * sh / h)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := (x + 1) * sw / w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.RGBAAt(sx, sy)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					b += uint32(c.B)
+					a += uint32(c.A)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,118 @@
+// Package m3u generates M3U playlists from the normalized channel model.
+package m3u
+
+import (
+	"fmt"
+	"strings"
+
+	"template-go-vercel/pkg/source"
+)
+
+// ContentType is the media type playlists are served with.
+const ContentType = "audio/x-mpegurl"
+
+type EXTINF struct {
+	// Id identifies the channel to the handlers and is written as tvg-id
+	// unless GuideID is set.
+	Id        string `extinf:"tvg-id"`
+	GuideID   string
+	Name      string `extinf:"tvg-name"`
+	Logo      string `extinf:"tvg-logo"`
+	Group     string `extinf:"group-title"`
+	Number    int    `extinf:"tvg-chno"`
+	Title     string
+	Url       string
+	SD        bool
+	HD        bool
+	FHD       bool
+	Prefix    string
+	NewName   string
+	MatchName string
+	// Catchup, CatchupDays and CatchupSource describe timeshift support.
+	Catchup       string `extinf:"catchup"`
+	CatchupDays   int    `extinf:"catchup-days"`
+	CatchupSource string `extinf:"catchup-source"`
+	// Entitlement explains the policy decision for the channel.
+	Entitlement string `extinf:"x-entitlement"`
+}
+
+// attrs returns the optional attributes of inf, each with a leading space.
+func (inf *EXTINF) attrs() string {
+	var b strings.Builder
+	if inf.Entitlement != "" {
+		fmt.Fprintf(&b, " x-entitlement=\"%s\"", inf.Entitlement)
+	}
+	if inf.Catchup != "" {
+		fmt.Fprintf(&b, " catchup=\"%s\"", inf.Catchup)
+		if inf.CatchupDays > 0 {
+			fmt.Fprintf(&b, " catchup-days=\"%d\"", inf.CatchupDays)
+		}
+		if inf.CatchupSource != "" {
+			fmt.Fprintf(&b, " catchup-source=\"%s\"", inf.CatchupSource)
+		}
+	}
+	return b.String()
+}
+
+// tvgID returns the id players match against the guide.
+func (inf *EXTINF) tvgID() string {
+	if inf.GuideID != "" {
+		return inf.GuideID
+	}
+	return inf.Id
+}
+
+type M3UData struct {
+	List []*EXTINF
+}
+
+func (m3u *M3UData) M3UData() []byte {
+	var stringSlice []string
+
+	for _, inf := range m3u.List {
+		if inf == nil {
+			continue
+		}
+		name := inf.Title
+
+		stringSlice = append(stringSlice, fmt.Sprintf(
+			"#EXTINF:-1 tvg-chno=\"%d\" tvg-id=\"%s\" tvg-name=\"%s\" tvg-logo=\"%s\" group-title=\"%s\"%s, %s \n%s\n",
+			inf.Number,
+			inf.tvgID(),
+			name,
+			inf.Logo,
+			inf.Group,
+			inf.attrs(),
+			name,
+			inf.Url,
+		))
+	}
+	stringByte := strings.Join(stringSlice, "\x0a")
+	return []byte(stringByte)
+}
+
+// StreamListToEXTINF converts channels to playlist entries. Channels
+// without a group are put in group. Channel numbers should already have
+// been assigned with package numbering.
+func StreamListToEXTINF(channels []source.Channel, group string) []*EXTINF {
+	var list []*EXTINF
+	for _, channel := range channels {
+		g := channel.Group
+		if g == "" {
+			g = group
+		}
+		list = append(list, &EXTINF{
+			Id:      channel.ID,
+			GuideID: channel.GuideID,
+			Name:    channel.Name,
+			NewName: channel.Name,
+			Logo:    channel.Logo,
+			Url:     channel.StreamURL,
+			Group:   g,
+			Number:  channel.Number,
+			Title:   channel.Name,
+			FHD:     true,
+		})
+	}
+	return list
+}
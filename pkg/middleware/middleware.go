@@ -0,0 +1,252 @@
+// Package middleware is the request chain shared by the handlers.
+//
+// Vercel calls each exported handler in api/ directly, so there is no
+// router to install middleware on. Instead every handler passes its body to
+// Serve, which runs it behind the default chain: request logging with a
+// request ID, panic recovery, security headers, CORS, method restriction
+// and a request timeout.
+//
+// CORS origins are configured with CORS_ORIGINS, a comma separated list of
+// origins or "*" (the default). The timeout is REQUEST_TIMEOUT, a Go
+// duration (default 25s; "0" or "off" disables it).
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/logging"
+)
+
+// Middleware wraps a handler with extra behavior.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in mws, the first of which runs first.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Default returns the chain every handler runs behind. methods are the
+// methods the handler accepts; none means GET and HEAD.
+func Default(name string, methods ...string) []Middleware {
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	return []Middleware{
+		Log(name),
+		Recover,
+		SecurityHeaders,
+		CORS(Origins(), methods),
+		Methods(methods...),
+		Timeout(RequestTimeout()),
+	}
+}
+
+// Serve runs h for the named handler behind the Default chain.
+func Serve(w http.ResponseWriter, r *http.Request, name string, h http.HandlerFunc, methods ...string) {
+	Chain(h, Default(name, methods...)...).ServeHTTP(w, r)
+}
+
+// Log tags the request with an ID, puts a request logger in its context
+// and logs the outcome. See logging.Begin.
+func Log(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w, r, done := logging.Begin(w, r, name)
+			defer done()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// startedWriter records whether the response has been started.
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (s *startedWriter) WriteHeader(code int) {
+	s.started = true
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *startedWriter) Write(b []byte) (int, error) {
+	s.started = true
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *startedWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Recover turns a panic into a logged 500, or, when the response has
+// already started, ends it where it is.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &startedWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			logging.From(r.Context()).Error("handler panicked",
+				"panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+			if !sw.started {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// SecurityHeaders sets headers that keep browsers from sniffing content
+// types, framing the pages or leaking full URLs to other sites.
+func SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			h.Set("Strict-Transport-Security", "max-age=63072000")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Origins returns the origins allowed by CORS_ORIGINS.
+func Origins() []string {
+	s := os.Getenv("CORS_ORIGINS")
+	if s == "" {
+		return []string{"*"}
+	}
+	var origins []string
+	for _, o := range strings.Split(s, ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// exposedHeaders are the response headers browser scripts may read.
+const exposedHeaders = "X-Request-Id, X-RateLimit-Limit, X-RateLimit-Remaining, Retry-After, Warning, X-KV-Expires-At"
+
+// CORS allows cross-origin requests from origins and answers preflight
+// requests for methods itself.
+func CORS(origins, methods []string) Middleware {
+	all := false
+	allowed := map[string]bool{}
+	for _, o := range origins {
+		if o == "*" {
+			all = true
+		}
+		allowed[o] = true
+	}
+	allowMethods := allowHeader(methods)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			if origin == "" || (!all && !allowed[origin]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if all {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", allowMethods)
+				h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				h.Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.Set("Access-Control-Expose-Headers", exposedHeaders)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowHeader lists methods and OPTIONS for Allow headers.
+func allowHeader(methods []string) string {
+	return strings.Join(append(methods[:len(methods):len(methods)], http.MethodOptions), ", ")
+}
+
+// Methods answers 405 Method Not Allowed to requests with any other
+// method, and OPTIONS requests with the Allow header.
+func Methods(methods ...string) Middleware {
+	allowed := map[string]bool{}
+	for _, m := range methods {
+		allowed[m] = true
+	}
+	allow := allowHeader(methods)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case allowed[r.Method]:
+				next.ServeHTTP(w, r)
+			case r.Method == http.MethodOptions:
+				w.Header().Set("Allow", allow)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.Header().Set("Allow", allow)
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			}
+		})
+	}
+}
+
+// DefaultTimeout is the request timeout when REQUEST_TIMEOUT is unset.
+const DefaultTimeout = 25 * time.Second
+
+// RequestTimeout returns REQUEST_TIMEOUT, or 0 when it is disabled.
+func RequestTimeout() time.Duration {
+	s := os.Getenv("REQUEST_TIMEOUT")
+	switch {
+	case s == "":
+		return DefaultTimeout
+	case s == "0" || strings.EqualFold(s, "off"):
+		return 0
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return DefaultTimeout
+}
+
+// Timeout cancels the request's context after d, which stops upstream
+// fetches and stream probes still in flight. A zero d never times out.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
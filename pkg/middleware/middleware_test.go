@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func ok(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+func serve(r *http.Request, h http.HandlerFunc, methods ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	Serve(w, r, "test", h, methods...)
+	return w
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	w := serve(httptest.NewRequest(http.MethodGet, "/", nil), ok)
+	for k, v := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "strict-origin-when-cross-origin",
+	} {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("%s: got %q, want %q", k, got, v)
+		}
+	}
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS over plain HTTP: got %q", got)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	if got := serve(r, ok).Header().Get("Strict-Transport-Security"); got == "" {
+		t.Error("HSTS missing behind an HTTPS proxy")
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "https://app.example.com")
+	called := false
+	h := func(w http.ResponseWriter, r *http.Request) { called = true }
+
+	r := httptest.NewRequest(http.MethodOptions, "/", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := serve(r, h, http.MethodGet, http.MethodPost)
+	if w.Code != http.StatusNoContent || called {
+		t.Fatalf("preflight: got %d, handler called %t", w.Code, called)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin: got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
+		t.Errorf("Access-Control-Allow-Methods: got %q", got)
+	}
+
+	r = httptest.NewRequest(http.MethodOptions, "/", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	if got := serve(r, h).Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("other origin: got Access-Control-Allow-Origin %q", got)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	w := serve(httptest.NewRequest(http.MethodDelete, "/", nil), ok, http.MethodGet, http.MethodPost)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("got %d, want 405", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, POST, OPTIONS" {
+		t.Errorf("Allow: got %q", got)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	w := serve(httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got %d, want 500", w.Code)
+	}
+}
+
+func TestTimeoutCancelsContext(t *testing.T) {
+	t.Setenv("REQUEST_TIMEOUT", "20ms")
+	var err error
+	serve(httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			err = r.Context().Err()
+		case <-time.After(time.Second):
+		}
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("got %v, want the context to be cancelled with DeadlineExceeded", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"":    DefaultTimeout,
+		"off": 0,
+		"0":   0,
+		"5s":  5 * time.Second,
+		"10":  10 * time.Second,
+		"bad": DefaultTimeout,
+	} {
+		t.Setenv("REQUEST_TIMEOUT", in)
+		if got := RequestTimeout(); got != want {
+			t.Errorf("REQUEST_TIMEOUT=%q: got %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package numbering assigns tvg-chno channel numbers.
+//
+// The strategy is picked with CHANNEL_NUMBERING:
+//
+//	index       position in the feed (the default)
+//	order       the upstream Order field
+//	source      the number the source already provides
+//	persistent  a stored id->number map, so numbers never change once given
+//
+// Numbers start at CHANNEL_NUMBER_START (default 0). CHANNEL_GROUP_START
+// gives individual groups their own starting number, as a comma separated
+// list of group=number pairs, for example "News=100,Sports=200".
+package numbering
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+)
+
+// Strategy selects how numbers are derived.
+type Strategy string
+
+const (
+	Index      Strategy = "index"
+	Order      Strategy = "order"
+	Source     Strategy = "source"
+	Persistent Strategy = "persistent"
+)
+
+// mapKey is the store key of the persistent id->number map.
+const mapKey = "numbering:map"
+
+// Numberer assigns channel numbers.
+type Numberer struct {
+	Strategy Strategy
+	// Start is the first number for groups without an entry in GroupStart.
+	Start      int
+	GroupStart map[string]int
+	// Store holds the map used by the Persistent strategy.
+	Store store.Store
+}
+
+// FromEnv returns a Numberer configured from CHANNEL_NUMBERING,
+// CHANNEL_NUMBER_START and CHANNEL_GROUP_START.
+func FromEnv() (*Numberer, error) {
+	n := &Numberer{Strategy: Index, GroupStart: map[string]int{}}
+	if s := os.Getenv("CHANNEL_NUMBERING"); s != "" {
+		n.Strategy = Strategy(strings.ToLower(s))
+	}
+	switch n.Strategy {
+	case Index, Order, Source:
+	case Persistent:
+		n.Store = store.FromEnv()
+	default:
+		return nil, fmt.Errorf("unknown CHANNEL_NUMBERING %q", n.Strategy)
+	}
+	if s := os.Getenv("CHANNEL_NUMBER_START"); s != "" {
+		start, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CHANNEL_NUMBER_START: %w", err)
+		}
+		n.Start = start
+	}
+	if s := os.Getenv("CHANNEL_GROUP_START"); s != "" {
+		for _, pair := range strings.Split(s, ",") {
+			group, num, ok := strings.Cut(pair, "=")
+			start, err := strconv.Atoi(strings.TrimSpace(num))
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid CHANNEL_GROUP_START entry %q", pair)
+			}
+			n.GroupStart[strings.TrimSpace(group)] = start
+		}
+	}
+	return n, nil
+}
+
+// Assign sets Number on every channel.
+func (n *Numberer) Assign(ctx context.Context, channels []source.Channel) error {
+	switch n.Strategy {
+	case Source:
+		return nil
+	case Persistent:
+		return n.assignPersistent(ctx, channels)
+	}
+
+	next := map[string]int{}
+	taken := map[int]bool{}
+	for i := range channels {
+		ch := &channels[i]
+		key, base := n.rangeOf(ch.GroupName())
+		num := base + next[key]
+		if n.Strategy == Order {
+			num = base + ch.Order
+		}
+		for taken[num] {
+			num++
+		}
+		taken[num] = true
+		next[key]++
+		ch.Number = num
+	}
+	return nil
+}
+
+// rangeOf returns the counter key and first number for a group. Groups
+// without their own start share the global range.
+func (n *Numberer) rangeOf(group string) (string, int) {
+	if start, ok := n.GroupStart[group]; ok {
+		return group, start
+	}
+	return "", n.Start
+}
+
+// assignPersistent gives new channels the next free number and saves the
+// map in one atomic store update, so concurrent invocations cannot hand the
+// same number to two channels.
+func (n *Numberer) assignPersistent(ctx context.Context, channels []source.Channel) error {
+	var numbers map[string]int
+	err := n.Store.Update(ctx, mapKey, func(old []byte) ([]byte, error) {
+		numbers = map[string]int{}
+		if old != nil {
+			if err := json.Unmarshal(old, &numbers); err != nil {
+				return nil, fmt.Errorf("decoding channel numbers: %w", err)
+			}
+		}
+		taken := map[int]bool{}
+		for _, num := range numbers {
+			taken[num] = true
+		}
+		changed := false
+		for _, ch := range channels {
+			if _, ok := numbers[ch.ID]; ok {
+				continue
+			}
+			_, num := n.rangeOf(ch.GroupName())
+			for taken[num] {
+				num++
+			}
+			taken[num] = true
+			numbers[ch.ID] = num
+			changed = true
+		}
+		if !changed {
+			return nil, nil
+		}
+		return json.Marshal(numbers)
+	})
+	if err != nil {
+		return fmt.Errorf("saving channel numbers: %w", err)
+	}
+	for i := range channels {
+		channels[i].Number = numbers[channels[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package numbering
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+)
+
+func TestAssignIndex(t *testing.T) {
+	n := &Numberer{Strategy: Index, Start: 1, GroupStart: map[string]int{"Sports": 100}}
+	channels := []source.Channel{{ID: "a"}, {ID: "b", Group: "Sports"}, {ID: "c"}}
+	if err := n.Assign(context.Background(), channels); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{1, 100, 2} {
+		if channels[i].Number != want {
+			t.Errorf("%s: got %d, want %d", channels[i].ID, channels[i].Number, want)
+		}
+	}
+}
+
+func TestAssignPersistent(t *testing.T) {
+	ctx := context.Background()
+	n := &Numberer{Strategy: Persistent, Start: 1, Store: &store.File{Dir: t.TempDir()}}
+
+	// Concurrent first runs with different feeds must agree on the
+	// numbers and never hand one number to two channels.
+	feeds := [][]string{{"a", "b"}, {"b", "c"}, {"c", "d"}, {"d", "a"}}
+	results := make([][]source.Channel, len(feeds))
+	var wg sync.WaitGroup
+	for i, ids := range feeds {
+		for _, id := range ids {
+			results[i] = append(results[i], source.Channel{ID: id})
+		}
+		wg.Add(1)
+		go func(channels []source.Channel) {
+			defer wg.Done()
+			if err := n.Assign(ctx, channels); err != nil {
+				t.Error(err)
+			}
+		}(results[i])
+	}
+	wg.Wait()
+
+	byID := map[string]int{}
+	owner := map[int]string{}
+	for _, channels := range results {
+		for _, ch := range channels {
+			if num, ok := byID[ch.ID]; ok && num != ch.Number {
+				t.Errorf("%s got both %d and %d", ch.ID, num, ch.Number)
+			}
+			if id, ok := owner[ch.Number]; ok && id != ch.ID {
+				t.Errorf("%d given to both %s and %s", ch.Number, id, ch.ID)
+			}
+			byID[ch.ID], owner[ch.Number] = ch.Number, ch.ID
+		}
+	}
+
+	// Later runs keep the numbers, whatever the feed order.
+	again := []source.Channel{{ID: "d"}, {ID: "c"}, {ID: "b"}, {ID: "a"}}
+	if err := n.Assign(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range again {
+		if ch.Number != byID[ch.ID] {
+			t.Errorf("%s: got %d, want %d", ch.ID, ch.Number, byID[ch.ID])
+		}
+	}
+}
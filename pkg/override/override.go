@@ -0,0 +1,139 @@
+// Package override applies per-channel customizations on top of the feed.
+//
+// Overrides are keyed by channel id and kept in the store as one JSON
+// document, so they survive upstream reorders, renames and additions. A
+// channel that disappears upstream keeps its override until it is deleted.
+// Changes go through Update, which re-reads the document under the store's
+// atomic update so concurrent edits of different channels are all kept.
+package override
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+)
+
+// key is the store key of the override document.
+const key = "overrides"
+
+// Override customizes one channel. Empty fields keep the feed's value.
+type Override struct {
+	Name  string `json:"name,omitempty"`
+	Logo  string `json:"logo,omitempty"`
+	Group string `json:"group,omitempty"`
+	// Number replaces the assigned tvg-chno. Channels are listed in number
+	// order once any number is overridden.
+	Number    *int   `json:"number,omitempty"`
+	Hidden    bool   `json:"hidden,omitempty"`
+	StreamURL string `json:"url,omitempty"`
+}
+
+// IsZero reports whether o changes nothing.
+func (o Override) IsZero() bool {
+	return o == Override{}
+}
+
+// Set maps channel ids to their overrides.
+type Set map[string]Override
+
+// Load reads the stored overrides. A missing document is an empty Set.
+func Load(ctx context.Context, s store.Store) (Set, error) {
+	set := Set{}
+	b, err := s.Get(ctx, key)
+	switch {
+	case err == store.ErrNotFound:
+		return set, nil
+	case err != nil:
+		return nil, fmt.Errorf("loading overrides: %w", err)
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("decoding overrides: %w", err)
+	}
+	return set, nil
+}
+
+// Update loads the stored overrides, lets fn change them and saves the
+// result atomically. fn may be called more than once when another update
+// gets in between, each time with the current overrides, so it must only
+// change set. An error from fn is returned as is and nothing is saved.
+// The saved overrides are returned.
+func Update(ctx context.Context, s store.Store, fn func(set Set) error) (Set, error) {
+	var set Set
+	var fnErr error
+	err := s.Update(ctx, key, func(old []byte) ([]byte, error) {
+		set = Set{}
+		if old != nil {
+			if err := json.Unmarshal(old, &set); err != nil {
+				return nil, fmt.Errorf("decoding overrides: %w", err)
+			}
+		}
+		if fnErr = fn(set); fnErr != nil {
+			return nil, fnErr
+		}
+		return json.Marshal(set)
+	})
+	switch {
+	case fnErr != nil:
+		return nil, fnErr
+	case err != nil:
+		return nil, fmt.Errorf("saving overrides: %w", err)
+	}
+	return set, nil
+}
+
+// Apply returns channels with the overrides applied and hidden channels
+// removed. The input slice is not modified.
+func (set Set) Apply(channels []source.Channel) []source.Channel {
+	out := make([]source.Channel, 0, len(channels))
+	renumbered := false
+	for _, ch := range channels {
+		o, ok := set[ch.ID]
+		if !ok {
+			out = append(out, ch)
+			continue
+		}
+		if o.Hidden {
+			continue
+		}
+		if o.Name != "" {
+			ch.Name = o.Name
+		}
+		if o.Logo != "" {
+			ch.Logo = o.Logo
+			ch.Logos = []string{o.Logo}
+		}
+		if o.Group != "" {
+			ch.Group = o.Group
+		}
+		if o.Number != nil {
+			ch.Number = *o.Number
+			renumbered = true
+		}
+		if o.StreamURL != "" {
+			ch.StreamURL = o.StreamURL
+			ch.GuideURL = ""
+		}
+		out = append(out, ch)
+	}
+	if renumbered {
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Number < out[j].Number })
+	}
+	return out
+}
+
+// FromStore loads the stored overrides and applies them to channels. When
+// they cannot be loaded the error is logged and channels are returned as is,
+// so a store outage does not take the playlist down.
+func FromStore(ctx context.Context, s store.Store, channels []source.Channel) []source.Channel {
+	set, err := Load(ctx, s)
+	if err != nil {
+		logging.From(ctx).Warn("loading channel overrides failed", "error", err)
+		return channels
+	}
+	return set.Apply(channels)
+}
@@ -0,0 +1,89 @@
+package override
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+)
+
+func TestUpdateConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := &store.File{Dir: t.TempDir()}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		id := "ch" + strconv.Itoa(i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Update(ctx, s, func(set Set) error {
+				set[id] = Override{Name: id}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	set, err := Load(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 10 {
+		t.Errorf("got %d overrides, want all 10 concurrent edits", len(set))
+	}
+}
+
+func TestUpdateError(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if _, err := Update(ctx, s, func(set Set) error {
+		set["tvj"] = Override{Hidden: true}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := errors.New("stop")
+	_, err := Update(ctx, s, func(set Set) error {
+		delete(set, "tvj")
+		return stop
+	})
+	if err != stop {
+		t.Errorf("got %v, want fn's error", err)
+	}
+	if set, _ := Load(ctx, s); !set["tvj"].Hidden {
+		t.Error("a failed update was saved")
+	}
+}
+
+func TestApply(t *testing.T) {
+	one := 1
+	set := Set{
+		"a": {Name: "Renamed", Number: &one, StreamURL: "https://cdn.example.com/new.m3u8"},
+		"b": {Hidden: true},
+	}
+	channels := []source.Channel{
+		{ID: "c", Number: 0},
+		{ID: "a", Name: "A", Number: 5, StreamURL: "https://cdn.example.com/a.m3u8", GuideURL: "https://cdn.example.com/a-hls.m3u8"},
+		{ID: "b", Number: 2},
+	}
+	out := set.Apply(channels)
+	if len(out) != 2 || out[0].ID != "c" || out[1].ID != "a" {
+		t.Fatalf("got %+v, want c then a", out)
+	}
+	a := out[1]
+	if a.Name != "Renamed" || a.Number != 1 || a.StreamURL != "https://cdn.example.com/new.m3u8" || a.GuideURL != "" {
+		t.Errorf("a: got %+v", a)
+	}
+	if channels[1].Name != "A" {
+		t.Error("Apply modified its input")
+	}
+}
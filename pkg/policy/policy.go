@@ -0,0 +1,218 @@
+// Package policy decides which channels a caller may see and which stream
+// variant they get, from the feed's PaidType, CommerceType,
+// SubscriptionsCategories, AllowedCountries and AdPolicyID fields.
+//
+// Callers are described by a Profile. Named profiles are defined in
+// POLICY_PROFILES as a JSON object, for example
+//
+//	{"family": {"tier": "subscriber", "subscriptions": ["premium"]}}
+//
+// and carried by playlist tokens (see package token). Admin requests may
+// also pick one with ?profile=family to preview it. Everyone else gets
+// POLICY_DEFAULT_TIER, or no restrictions at all when it is unset. Country
+// restrictions apply to every tier once GEO_RESTRICT is enabled; callers
+// whose country is unknown only get unrestricted channels unless
+// GEO_UNKNOWN=allow.
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"template-go-vercel/pkg/auth"
+	"template-go-vercel/pkg/geo"
+	"template-go-vercel/pkg/source"
+)
+
+// Tiers understood by Decide.
+const (
+	Unrestricted = "unrestricted"
+	Free         = "free"
+	Subscriber   = "subscriber"
+)
+
+// AnySubscription in Profile.Subscriptions grants every subscription category.
+const AnySubscription = "*"
+
+// ErrUnknownProfile is returned for profile names missing from POLICY_PROFILES.
+var ErrUnknownProfile = errors.New("policy: unknown profile")
+
+// Profile describes a caller.
+type Profile struct {
+	Name          string   `json:"-"`
+	Tier          string   `json:"tier"`
+	Subscriptions []string `json:"subscriptions,omitempty"`
+	// Country is the caller's ISO 3166-1 alpha-2 code, when known.
+	Country string `json:"country,omitempty"`
+}
+
+// Variant is the stream handed to the caller.
+type Variant string
+
+const (
+	Normal  Variant = "normal"
+	Blocked Variant = "blocked"
+)
+
+// Decision is the outcome for one channel.
+type Decision struct {
+	Include bool    `json:"include"`
+	Variant Variant `json:"variant,omitempty"`
+	Reason  string  `json:"reason"`
+}
+
+// Lookup returns the named profile from POLICY_PROFILES.
+func Lookup(name string) (Profile, error) {
+	profiles := map[string]Profile{}
+	if raw := os.Getenv("POLICY_PROFILES"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &profiles); err != nil {
+			return Profile{}, fmt.Errorf("parsing POLICY_PROFILES: %w", err)
+		}
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, ErrUnknownProfile
+	}
+	p.Name = name
+	return p, nil
+}
+
+// Default returns the profile for callers that did not pick one.
+func Default() Profile {
+	if tier := os.Getenv("POLICY_DEFAULT_TIER"); tier != "" {
+		return Profile{Name: "default", Tier: tier}
+	}
+	return Profile{Name: "default", Tier: Unrestricted}
+}
+
+// FromRequest returns the profile of a caller without a playlist token:
+// Default, or for admin requests the profile named by ?profile=. Anonymous
+// callers cannot pick a profile, as that would let them pick a paid tier.
+func FromRequest(r *http.Request) (Profile, error) {
+	if auth.Admin(r) {
+		return ForRequest(r, r.URL.Query().Get("profile"))
+	}
+	return ForRequest(r, "")
+}
+
+// ForRequest returns the named profile, or Default when name is empty. When
+// geo restriction is enabled the caller's country is filled in from r, as
+// geo.Unknown when it cannot be determined and GEO_UNKNOWN does not allow
+// that.
+func ForRequest(r *http.Request, name string) (Profile, error) {
+	p := Default()
+	if name != "" {
+		var err error
+		if p, err = Lookup(name); err != nil {
+			return p, err
+		}
+	}
+	if p.Country == "" && geo.Enabled() {
+		p.Country = geo.Country(r)
+		if p.Country == "" && !geo.AllowUnknown() {
+			p.Country = geo.Unknown
+		}
+	}
+	return p, nil
+}
+
+// Paid reports whether ch requires a subscription.
+func Paid(ch source.Channel) bool {
+	switch strings.ToLower(ch.PaidType) {
+	case "", "free":
+	default:
+		return true
+	}
+	switch strings.ToLower(ch.CommerceType) {
+	case "", "free", "avod":
+		return false
+	}
+	return true
+}
+
+// Decide applies the profile to one channel.
+func Decide(ch source.Channel, p Profile) Decision {
+	if !ch.AllowedCountries.Allows(p.Country) {
+		if p.Country == geo.Unknown {
+			return fallback(ch, "country unknown")
+		}
+		return fallback(ch, "not available in "+strings.ToUpper(p.Country))
+	}
+	if p.Tier == Unrestricted {
+		return Decision{Include: true, Variant: Normal, Reason: Unrestricted}
+	}
+	if Paid(ch) && !entitled(ch, p) {
+		reason := "requires subscription"
+		if len(ch.SubscriptionsCategories) > 0 {
+			reason += ": " + strings.Join(ch.SubscriptionsCategories, ",")
+		}
+		return fallback(ch, reason)
+	}
+
+	reason := "free"
+	if Paid(ch) {
+		reason = "subscribed"
+	}
+	if ch.AdPolicyID != "" {
+		reason += "; ad policy " + ch.AdPolicyID
+	}
+	return Decision{Include: true, Variant: Normal, Reason: reason}
+}
+
+// fallback hands out the blocked variant when the feed has one and drops the
+// channel otherwise.
+func fallback(ch source.Channel, reason string) Decision {
+	if ch.BlockedStreamURL != "" {
+		return Decision{Include: true, Variant: Blocked, Reason: reason}
+	}
+	return Decision{Include: false, Reason: reason}
+}
+
+func entitled(ch source.Channel, p Profile) bool {
+	if p.Tier != Subscriber {
+		return false
+	}
+	if len(ch.SubscriptionsCategories) == 0 || contains(p.Subscriptions, AnySubscription) {
+		return true
+	}
+	for _, c := range ch.SubscriptionsCategories {
+		if contains(p.Subscriptions, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply decides every channel, drops the excluded ones and swaps in the
+// blocked stream where required. The decisions are returned keyed by
+// channel id.
+func Apply(channels []source.Channel, p Profile) ([]source.Channel, map[string]Decision) {
+	decisions := make(map[string]Decision, len(channels))
+	var out []source.Channel
+	for _, ch := range channels {
+		d := Decide(ch, p)
+		decisions[ch.ID] = d
+		if !d.Include {
+			continue
+		}
+		if d.Variant == Blocked {
+			ch.StreamURL = ch.BlockedStreamURL
+			ch.GuideURL = ""
+		}
+		out = append(out, ch)
+	}
+	return out, decisions
+}
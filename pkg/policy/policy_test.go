@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"template-go-vercel/pkg/source"
+)
+
+func TestFromRequestProfile(t *testing.T) {
+	t.Setenv("POLICY_PROFILES", `{"family": {"tier": "subscriber", "subscriptions": ["premium"]}}`)
+	t.Setenv("POLICY_DEFAULT_TIER", Free)
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+
+	r := httptest.NewRequest("GET", "/M3U?profile=family", nil)
+	p, err := FromRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "default" || p.Tier != Free {
+		t.Errorf("anonymous ?profile=family: got %+v, want the default free profile", p)
+	}
+
+	r.Header.Set("Authorization", "Bearer admin-secret")
+	p, err = FromRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "family" || p.Tier != Subscriber {
+		t.Errorf("admin ?profile=family: got %+v, want family", p)
+	}
+
+	r = httptest.NewRequest("GET", "/M3U?profile=nope", nil)
+	r.Header.Set("Authorization", "Bearer admin-secret")
+	if _, err := FromRequest(r); err != ErrUnknownProfile {
+		t.Errorf("admin ?profile=nope: got %v, want ErrUnknownProfile", err)
+	}
+}
+
+func TestDecide(t *testing.T) {
+	free := Profile{Tier: Free}
+	family := Profile{Tier: Subscriber, Subscriptions: []string{"premium"}}
+	jm := Profile{Tier: Unrestricted, Country: "JM"}
+
+	paid := source.Channel{ID: "paid", PaidType: "paid", SubscriptionsCategories: []string{"premium"}}
+	paidWithSlate := paid
+	paidWithSlate.BlockedStreamURL = "https://cdn.example.com/slate.m3u8"
+	usOnly := source.Channel{ID: "us", AllowedCountries: source.Countries{"US"}}
+
+	tests := []struct {
+		name    string
+		ch      source.Channel
+		p       Profile
+		include bool
+		variant Variant
+	}{
+		{"free channel, free tier", source.Channel{ID: "free"}, free, true, Normal},
+		{"paid channel, free tier", paid, free, false, ""},
+		{"paid channel with slate, free tier", paidWithSlate, free, true, Blocked},
+		{"paid channel, subscriber", paid, family, true, Normal},
+		{"other country, unrestricted", usOnly, jm, false, ""},
+	}
+	for _, tt := range tests {
+		d := Decide(tt.ch, tt.p)
+		if d.Include != tt.include || d.Variant != tt.variant {
+			t.Errorf("%s: got %+v, want include=%v variant=%q", tt.name, d, tt.include, tt.variant)
+		}
+	}
+}
+
+func TestUnknownCountry(t *testing.T) {
+	t.Setenv("GEO_RESTRICT", "1")
+	t.Setenv("GEO_PLATFORM", "vercel")
+	t.Setenv("GEOIP_CSV", "")
+	t.Setenv("POLICY_DEFAULT_TIER", "")
+	usOnly := source.Channel{ID: "us", AllowedCountries: source.Countries{"US"}}
+	everywhere := source.Channel{ID: "all"}
+
+	tests := []struct {
+		unknown string
+		want    bool
+	}{
+		{"", false},
+		{"deny", false},
+		{"allow", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("GEO_UNKNOWN", tt.unknown)
+		p, err := ForRequest(httptest.NewRequest("GET", "/M3U", nil), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := Decide(usOnly, p); d.Include != tt.want {
+			t.Errorf("GEO_UNKNOWN=%q: restricted channel got %+v, want include=%v", tt.unknown, d, tt.want)
+		}
+		if d := Decide(everywhere, p); !d.Include {
+			t.Errorf("GEO_UNKNOWN=%q: unrestricted channel got %+v", tt.unknown, d)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/M3U", nil)
+	r.Header.Set("X-Vercel-IP-Country", "us")
+	if p, _ := ForRequest(r, ""); p.Country != "US" || !Decide(usOnly, p).Include {
+		t.Errorf("US caller: got country %q", p.Country)
+	}
+}
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"template-go-vercel/pkg/logging"
+)
+
+// Counter stores hit counts per window key.
+type Counter interface {
+	// Hit increments cur, expiring it after ttl, and returns its new value
+	// together with the value of prev.
+	Hit(ctx context.Context, cur, prev string, ttl time.Duration) (int64, int64, error)
+	// Undo takes back the last hit on key.
+	Undo(ctx context.Context, key string) error
+}
+
+// Redis counts hits with INCR on keys that expire on their own.
+type Redis struct {
+	Client *redis.Client
+}
+
+func (c *Redis) Hit(ctx context.Context, cur, prev string, ttl time.Duration) (int64, int64, error) {
+	pipe := c.Client.TxPipeline()
+	incr := pipe.Incr(ctx, cur)
+	pipe.PExpire(ctx, cur, ttl)
+	get := pipe.Get(ctx, prev)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, 0, err
+	}
+	prevN, err := get.Int64()
+	if err != nil && err != redis.Nil {
+		return 0, 0, err
+	}
+	return incr.Val(), prevN, nil
+}
+
+func (c *Redis) Undo(ctx context.Context, key string) error {
+	return c.Client.Decr(ctx, key).Err()
+}
+
+type memoryEntry struct {
+	n       int64
+	expires time.Time
+}
+
+// Memory counts hits in this process only.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	// Now returns the current time; tests may replace it.
+	Now func() time.Time
+}
+
+// NewMemory returns an empty in-memory counter.
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]memoryEntry{}}
+}
+
+func (c *Memory) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *Memory) Hit(ctx context.Context, cur, prev string, ttl time.Duration) (int64, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	e := c.entries[cur]
+	e.n++
+	e.expires = now.Add(ttl)
+	c.entries[cur] = e
+	return e.n, c.entries[prev].n, nil
+}
+
+func (c *Memory) Undo(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok && e.n > 0 {
+		e.n--
+		c.entries[key] = e
+	}
+	return nil
+}
+
+// fallbackPeriod is how long Fallback keeps using Secondary after Primary
+// fails, so a dead Redis is not retried on every request.
+const fallbackPeriod = 30 * time.Second
+
+// Fallback counts in Primary, switching to Secondary for a while whenever
+// Primary fails.
+type Fallback struct {
+	Primary, Secondary Counter
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func (c *Fallback) active() Counter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.downUntil) {
+		return c.Secondary
+	}
+	return c.Primary
+}
+
+func (c *Fallback) Hit(ctx context.Context, cur, prev string, ttl time.Duration) (int64, int64, error) {
+	counter := c.active()
+	curN, prevN, err := counter.Hit(ctx, cur, prev, ttl)
+	if err == nil || counter == c.Secondary {
+		return curN, prevN, err
+	}
+	logging.From(ctx).Warn("redis rate limit counter failed, using memory", "error", err)
+	c.mu.Lock()
+	c.downUntil = time.Now().Add(fallbackPeriod)
+	c.mu.Unlock()
+	return c.Secondary.Hit(ctx, cur, prev, ttl)
+}
+
+func (c *Fallback) Undo(ctx context.Context, key string) error {
+	return c.active().Undo(ctx, key)
+}
@@ -0,0 +1,211 @@
+// Package ratelimit limits how often a client may call a handler.
+//
+// Limits use a sliding window counter: hits are counted in fixed windows
+// and the previous window's count is weighted by how much of it still
+// overlaps the sliding window. Counters live in Redis so every function
+// instance shares them, with a per-instance in-memory fallback when Redis
+// is unavailable.
+//
+// Limits are configured per handler with RATE_LIMIT_<NAME>, falling back to
+// RATE_LIMIT, as "<requests>/<window>", e.g. "60/1m" or "10/h". Handlers
+// without a configured limit are not limited.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"template-go-vercel/pkg/geo"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/rdb"
+	"template-go-vercel/pkg/token"
+)
+
+// Rule allows Limit requests per Window.
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ParseRule parses "<requests>/<window>". The window is a Go duration; a
+// bare unit such as "m" means one of it.
+func ParseRule(s string) (Rule, error) {
+	n, w, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit %q: want <requests>/<window>", s)
+	}
+	limit, err := strconv.Atoi(n)
+	if err != nil || limit <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: invalid request count", s)
+	}
+	if w != "" && (w[0] < '0' || w[0] > '9') {
+		w = "1" + w
+	}
+	window, err := time.ParseDuration(w)
+	if err != nil || window <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: invalid window", s)
+	}
+	return Rule{Limit: limit, Window: window}, nil
+}
+
+// RuleFor returns the configured rule for the named handler, and false when
+// it is not limited.
+func RuleFor(name string) (Rule, bool, error) {
+	s := os.Getenv("RATE_LIMIT_" + strings.ToUpper(name))
+	if s == "" {
+		s = os.Getenv("RATE_LIMIT")
+	}
+	if s == "" || s == "0" || strings.EqualFold(s, "off") {
+		return Rule{}, false, nil
+	}
+	rule, err := ParseRule(s)
+	return rule, err == nil, err
+}
+
+// Result describes the outcome of a single hit.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a denied client should wait.
+	RetryAfter time.Duration
+}
+
+// Limiter enforces a Rule on keys, counting hits in Counter.
+type Limiter struct {
+	Rule    Rule
+	Counter Counter
+	// Now returns the current time; tests may replace it.
+	Now func() time.Time
+}
+
+// Allow records a hit for key and reports whether it is within the limit.
+// Denied hits are not counted, so a client that backs off as told by
+// RetryAfter is let through again.
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	now := time.Now()
+	if l.Now != nil {
+		now = l.Now()
+	}
+	window := l.Rule.Window
+	idx := now.UnixNano() / int64(window)
+	elapsed := float64(now.UnixNano()-idx*int64(window)) / float64(window)
+
+	cur := fmt.Sprintf("%s:%d", key, idx)
+	prev := fmt.Sprintf("%s:%d", key, idx-1)
+	curN, prevN, err := l.Counter.Hit(ctx, cur, prev, 2*window)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Limit: l.Rule.Limit}
+	limit := float64(l.Rule.Limit)
+	estimate := float64(prevN)*(1-elapsed) + float64(curN)
+	if estimate <= limit {
+		res.Allowed = true
+		res.Remaining = int(limit - estimate)
+		return res, nil
+	}
+
+	if err := l.Counter.Undo(ctx, cur); err != nil {
+		return Result{}, err
+	}
+	curN--
+	// Find the time at which one more hit fits: within this window while
+	// the previous window's weight decays, or otherwise in the next one
+	// while this window's does.
+	var wait float64
+	if room := limit - 1 - float64(curN); room >= 0 && prevN > 0 {
+		wait = 1 - room/float64(prevN) - elapsed
+	} else {
+		wait = 1 - elapsed
+		if curN > 0 {
+			wait += math.Max(0, 1-(limit-1)/float64(curN))
+		}
+	}
+	res.RetryAfter = time.Duration(wait * float64(window))
+	if res.RetryAfter < time.Second {
+		res.RetryAfter = time.Second
+	}
+	return res, nil
+}
+
+// ClientKey identifies the caller: by the id of tok, a playlist token
+// already verified by token.Authorize, and by client IP when there is none.
+// Unverified ?token= values are never used, as anyone could make them up to
+// get a fresh limit.
+func ClientKey(r *http.Request, tok string) string {
+	if tok != "" {
+		if claims, err := token.Parse(tok); err == nil {
+			return "token:" + claims.ID
+		}
+	}
+	if ip, ok := geo.ClientIP(r); ok {
+		return "ip:" + ip.String()
+	}
+	return "ip:unknown"
+}
+
+var (
+	sharedOnce sync.Once
+	shared     Counter
+)
+
+// sharedCounter counts in Redis, falling back to this instance's memory,
+// or in memory only when Redis is not configured.
+func sharedCounter() Counter {
+	sharedOnce.Do(func() {
+		mem := NewMemory()
+		client, err := rdb.Client()
+		if err != nil {
+			if rdb.Enabled() {
+				slog.Warn("redis unavailable, rate limiting in memory", "error", err)
+			}
+			shared = mem
+			return
+		}
+		shared = &Fallback{Primary: &Redis{Client: client}, Secondary: mem}
+	})
+	return shared
+}
+
+// Check applies the named handler's limit to r, keyed by ClientKey, so
+// handlers that take playlist tokens call it after token.Authorize with the
+// verified token. It sets the X-RateLimit headers and returns true when the
+// request may proceed; otherwise it answers 429 with Retry-After and
+// returns false. Configuration errors are logged and let the request
+// through.
+func Check(w http.ResponseWriter, r *http.Request, name, tok string) bool {
+	rule, ok, err := RuleFor(name)
+	if err != nil {
+		logging.From(r.Context()).Error("invalid rate limit", "error", err)
+		return true
+	}
+	if !ok {
+		return true
+	}
+	l := &Limiter{Rule: rule, Counter: sharedCounter()}
+	res, err := l.Allow(r.Context(), "ratelimit:"+name+":"+ClientKey(r, tok))
+	if err != nil {
+		logging.From(r.Context()).Error("rate limit check failed", "error", err)
+		return true
+	}
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	if res.Allowed {
+		return true
+	}
+	secs := int(math.Ceil(res.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, fmt.Sprintf("rate limit exceeded, retry in %ds", secs), http.StatusTooManyRequests)
+	return false
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"template-go-vercel/pkg/token"
+)
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *Redis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, &Redis{Client: client}
+}
+
+func TestParseRule(t *testing.T) {
+	for in, want := range map[string]Rule{
+		"60/1m":  {Limit: 60, Window: time.Minute},
+		"10/h":   {Limit: 10, Window: time.Hour},
+		" 5/30s": {Limit: 5, Window: 30 * time.Second},
+	} {
+		got, err := ParseRule(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRule(%q): got %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"60", "0/1m", "x/1m", "60/", "60/-1m"} {
+		if _, err := ParseRule(in); err == nil {
+			t.Errorf("ParseRule(%q): want an error", in)
+		}
+	}
+}
+
+func TestRedisCounter(t *testing.T) {
+	mr, c := newRedis(t)
+	ctx := context.Background()
+
+	mr.Set("k:0", "4")
+	for want := int64(1); want <= 3; want++ {
+		cur, prev, err := c.Hit(ctx, "k:1", "k:0", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cur != want || prev != 4 {
+			t.Errorf("hit %d: got %d, %d; want %d, 4", want, cur, prev, want)
+		}
+	}
+	if ttl := mr.TTL("k:1"); ttl != time.Minute {
+		t.Errorf("TTL: got %v, want 1m", ttl)
+	}
+	if err := c.Undo(ctx, "k:1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mr.Get("k:1"); got != "2" {
+		t.Errorf("after Undo: got %s, want 2", got)
+	}
+
+	// A missing previous window counts as zero.
+	if _, prev, err := c.Hit(ctx, "other:1", "other:0", time.Minute); err != nil || prev != 0 {
+		t.Errorf("missing previous window: got %d, %v", prev, err)
+	}
+
+	mr.FastForward(time.Minute + time.Second)
+	if mr.Exists("k:1") {
+		t.Error("window key did not expire")
+	}
+}
+
+// testLimiter checks a 3/1m rule against counter at a fixed clock.
+func testLimiter(t *testing.T, counter Counter) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	l := &Limiter{Rule: Rule{Limit: 3, Window: time.Minute}, Counter: counter, Now: func() time.Time { return now }}
+
+	for i := 0; i < 3; i++ {
+		res, err := l.Allow(ctx, "client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Errorf("hit %d: got %+v", i+1, res)
+		}
+	}
+	res, err := l.Allow(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > 2*time.Minute {
+		t.Errorf("4th hit: got %+v, want denied with a retry time", res)
+	}
+	if res, _ := l.Allow(ctx, "other"); !res.Allowed {
+		t.Error("another client was limited")
+	}
+
+	// Denied hits are not counted, so waiting as told is enough.
+	now = now.Add(res.RetryAfter)
+	if res, _ := l.Allow(ctx, "client"); !res.Allowed {
+		t.Errorf("after Retry-After: got %+v", res)
+	}
+}
+
+func TestLimiterMemory(t *testing.T) {
+	testLimiter(t, NewMemory())
+}
+
+func TestLimiterRedis(t *testing.T) {
+	_, c := newRedis(t)
+	testLimiter(t, c)
+}
+
+func TestFallback(t *testing.T) {
+	mr, c := newRedis(t)
+	mem := NewMemory()
+	f := &Fallback{Primary: c, Secondary: mem}
+	ctx := context.Background()
+
+	if n, _, err := f.Hit(ctx, "k:1", "k:0", time.Minute); err != nil || n != 1 {
+		t.Fatalf("with Redis: got %d, %v", n, err)
+	}
+	mr.Close()
+	if n, _, err := f.Hit(ctx, "k:1", "k:0", time.Minute); err != nil || n != 1 {
+		t.Fatalf("Redis down: got %d, %v; want the first hit in memory", n, err)
+	}
+	if f.active() != mem {
+		t.Error("Fallback did not stay on memory after a Redis failure")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	t.Setenv("PLAYLIST_SECRET", "secret")
+	t.Setenv("GEO_PLATFORM", "direct")
+	tok, claims, err := token.Issue("alice", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/M3U?token=made-up", nil)
+	r.RemoteAddr = "203.0.113.9:1234"
+	if got := ClientKey(r, ""); got != "ip:203.0.113.9" {
+		t.Errorf("unverified ?token=: got %q, want the IP", got)
+	}
+	if got := ClientKey(r, tok); got != "token:"+claims.ID {
+		t.Errorf("verified token: got %q, want its id", got)
+	}
+	if got := ClientKey(r, "forged.token"); got != "ip:203.0.113.9" {
+		t.Errorf("invalid token: got %q, want the IP", got)
+	}
+}
@@ -0,0 +1,414 @@
+// Package reminder fires webhooks shortly before programmes start.
+//
+// A reminder watches for programmes whose title matches a pattern,
+// optionally on one channel, or for one specific programme given by channel
+// and start time. The scheduler is run periodically, by a Vercel cron job
+// calling /api/scheduler or by Loop in a long-running process, and posts
+// each matching programme once, from Lead minutes before it starts until it
+// ends. Webhooks go only to public addresses and are signed with
+// WEBHOOK_SECRET, without which reminders are not delivered.
+package reminder
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/webhook"
+)
+
+// Event is the webhook event name of reminders.
+const Event = "programme.reminder"
+
+const (
+	remindersKey = "reminders"
+	sentKey      = "reminders:sent"
+)
+
+// sentRetention is how long after a programme started its delivery is
+// remembered.
+const sentRetention = 24 * time.Hour
+
+const (
+	// DefaultConcurrency is how many webhooks a run sends at once.
+	DefaultConcurrency = 8
+	// DefaultBudget bounds the time a run spends sending webhooks. It is
+	// kept well below the default request timeout, so a run always has
+	// time left to unmark the deliveries it could not make.
+	DefaultBudget = 15 * time.Second
+)
+
+// unmarkTimeout bounds the write that unmarks failed deliveries, which is
+// made even when the run's context is done.
+const unmarkTimeout = 3 * time.Second
+
+// ErrNotFound is returned for unknown reminder ids.
+var ErrNotFound = errors.New("reminder not found")
+
+// DefaultLead returns REMINDER_LEAD, the minutes before the start at which
+// reminders without their own lead fire, defaulting to 10.
+func DefaultLead() int {
+	if n, err := strconv.Atoi(os.Getenv("REMINDER_LEAD")); err == nil && n > 0 {
+		return n
+	}
+	return 10
+}
+
+// Reminder is a registered watch.
+type Reminder struct {
+	ID string `json:"id"`
+	// Owner is the token subject that registered the reminder, or "admin".
+	Owner string `json:"owner"`
+	// Title is a case-insensitive regular expression matched against
+	// programme titles. Empty matches every title.
+	Title string `json:"title,omitempty"`
+	// ChannelID limits the reminder to one channel.
+	ChannelID string `json:"channel_id,omitempty"`
+	// Start picks a single programme on ChannelID.
+	Start *time.Time `json:"start,omitempty"`
+	// Lead is how many minutes before the start the webhook fires.
+	Lead      int       `json:"lead_minutes"`
+	Webhook   string    `json:"webhook_url"`
+	CreatedAt time.Time `json:"created_at"`
+
+	title *regexp.Regexp
+}
+
+// Validate checks r and compiles its title pattern.
+func (r *Reminder) Validate() error {
+	if r.Title == "" && r.Start == nil {
+		return errors.New("a reminder needs a title pattern or a programme start")
+	}
+	if r.Start != nil && r.ChannelID == "" {
+		return errors.New("a programme start needs a channel_id")
+	}
+	if r.Lead < 0 || r.Lead > 24*60 {
+		return errors.New("lead_minutes must be between 0 and 1440")
+	}
+	if err := webhook.CheckURL(r.Webhook); err != nil {
+		return err
+	}
+	if r.Title != "" {
+		re, err := regexp.Compile("(?i)" + r.Title)
+		if err != nil {
+			return fmt.Errorf("invalid title pattern: %w", err)
+		}
+		r.title = re
+	}
+	return nil
+}
+
+// Matches reports whether p on ch is one the reminder watches. r must
+// have been validated.
+func (r *Reminder) Matches(ch source.Channel, p source.Programme) bool {
+	if r.ChannelID != "" && r.ChannelID != ch.ID {
+		return false
+	}
+	if r.Start != nil && !r.Start.Equal(p.Start) {
+		return false
+	}
+	return r.title == nil || r.title.MatchString(p.Title)
+}
+
+// NewID returns a random reminder id.
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Set maps reminder ids to reminders.
+type Set map[string]*Reminder
+
+// Load reads the stored reminders. A missing document is an empty Set.
+func Load(ctx context.Context, s store.Store) (Set, error) {
+	set := Set{}
+	if err := loadJSON(ctx, s, remindersKey, &set); err != nil {
+		return nil, fmt.Errorf("loading reminders: %w", err)
+	}
+	for id, r := range set {
+		if err := r.Validate(); err != nil {
+			logging.From(ctx).Warn("skipping invalid stored reminder", "reminder_id", id, "error", err)
+			delete(set, id)
+		}
+	}
+	return set, nil
+}
+
+// Update loads the stored reminders, lets fn change them and saves the
+// result atomically. fn may be called more than once, each time with the
+// current reminders, so it must only change set. An error from fn is
+// returned as is and nothing is saved.
+func Update(ctx context.Context, s store.Store, fn func(set Set) error) error {
+	var fnErr error
+	err := s.Update(ctx, remindersKey, func(old []byte) ([]byte, error) {
+		set := Set{}
+		if old != nil {
+			if err := json.Unmarshal(old, &set); err != nil {
+				return nil, fmt.Errorf("decoding reminders: %w", err)
+			}
+		}
+		if fnErr = fn(set); fnErr != nil {
+			return nil, fnErr
+		}
+		return json.Marshal(set)
+	})
+	switch {
+	case fnErr != nil:
+		return fnErr
+	case err != nil:
+		return fmt.Errorf("saving reminders: %w", err)
+	}
+	return nil
+}
+
+func loadJSON(ctx context.Context, s store.Store, key string, v interface{}) error {
+	b, err := s.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Notification is the webhook payload.
+type Notification struct {
+	Event      string           `json:"event"`
+	ReminderID string           `json:"reminder_id"`
+	Channel    ChannelInfo      `json:"channel"`
+	Programme  source.Programme `json:"programme"`
+	StartsIn   int              `json:"starts_in_minutes"`
+}
+
+// ChannelInfo identifies the channel of a Notification.
+type ChannelInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Logo string `json:"logo,omitempty"`
+}
+
+// Delivery reports one webhook sent by Run.
+type Delivery struct {
+	ReminderID string    `json:"reminder_id"`
+	ChannelID  string    `json:"channel_id"`
+	Title      string    `json:"title"`
+	Start      time.Time `json:"start"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Scheduler finds due reminders and delivers them.
+type Scheduler struct {
+	Store  store.Store
+	Sender *webhook.Sender
+	// Now returns the current time; tests may replace it with a fake clock.
+	Now func() time.Time
+	// Concurrency is how many webhooks are sent at once, DefaultConcurrency
+	// if zero.
+	Concurrency int
+	// Budget bounds the time spent sending webhooks, DefaultBudget if
+	// zero. It is shortened to end before the context's deadline.
+	Budget time.Duration
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Scheduler) concurrency() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+	return DefaultConcurrency
+}
+
+// budget returns how long a run may spend sending webhooks under ctx,
+// leaving time to unmark failures before its deadline.
+func (s *Scheduler) budget(ctx context.Context) time.Duration {
+	b := s.Budget
+	if b <= 0 {
+		b = DefaultBudget
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline) - unmarkTimeout; left < b {
+			b = left
+		}
+	}
+	return b
+}
+
+func sentID(reminderID, channelID string, start time.Time) string {
+	return reminderID + "|" + channelID + "|" + strconv.FormatInt(start.Unix(), 10)
+}
+
+// due reports whether a reminder with the given lead fires for p at now:
+// from lead before the start until the programme ends, so runs may be late
+// or skipped, and a lead of zero or shorter than the run interval still
+// fires once.
+func due(p source.Programme, lead time.Duration, now time.Time) bool {
+	end := p.End
+	if end.IsZero() {
+		end = p.Start
+	}
+	return !now.Before(p.Start.Add(-lead)) && (now.Before(end) || now.Equal(p.Start))
+}
+
+// Run sends every reminder that is due for channels' programmes and has
+// not been sent yet. Deliveries are marked as sent before they are made,
+// so concurrent runs do not send them twice. Webhooks are sent
+// Concurrency at a time within Budget, and deliveries that failed or were
+// cut short, also by ctx being cancelled, are unmarked to be retried on
+// the next run.
+func (s *Scheduler) Run(ctx context.Context, channels []source.Channel) ([]Delivery, error) {
+	set, err := Load(ctx, s.Store)
+	if err != nil {
+		return nil, err
+	}
+
+	type pending struct {
+		id string
+		r  *Reminder
+		ch source.Channel
+		p  source.Programme
+	}
+	now := s.now()
+	var candidates []pending
+	for _, r := range set {
+		lead := time.Duration(r.Lead) * time.Minute
+		for _, ch := range channels {
+			for _, p := range ch.Programmes {
+				if due(p, lead, now) && r.Matches(ch, p) {
+					candidates = append(candidates, pending{sentID(r.ID, ch.ID, p.Start), r, ch, p})
+				}
+			}
+		}
+	}
+
+	// Claim the deliveries no other run has sent or claimed.
+	var claimed []pending
+	err = s.updateSent(ctx, func(sent map[string]time.Time) {
+		claimed = claimed[:0]
+		for _, c := range candidates {
+			if _, ok := sent[c.id]; !ok {
+				sent[c.id] = c.p.Start
+				claimed = append(claimed, c)
+			}
+		}
+		for id, start := range sent {
+			if now.Sub(start) > sentRetention {
+				delete(sent, id)
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("saving sent reminders: %w", err)
+	}
+
+	deliveries := make([]Delivery, len(claimed))
+	errs := make([]error, len(claimed))
+	sendCtx, cancel := context.WithTimeout(ctx, s.budget(ctx))
+	defer cancel()
+	sem := make(chan struct{}, s.concurrency())
+	var wg sync.WaitGroup
+	for i, c := range claimed {
+		deliveries[i] = Delivery{ReminderID: c.r.ID, ChannelID: c.ch.ID, Title: c.p.Title, Start: c.p.Start}
+		select {
+		case sem <- struct{}{}:
+		case <-sendCtx.Done():
+			errs[i] = sendCtx.Err()
+			continue
+		}
+		n := Notification{
+			Event:      Event,
+			ReminderID: c.r.ID,
+			Channel:    ChannelInfo{ID: c.ch.ID, Name: c.ch.Name, Logo: c.ch.Logo},
+			Programme:  c.p,
+			StartsIn:   int(c.p.Start.Sub(now).Round(time.Minute) / time.Minute),
+		}
+		wg.Add(1)
+		go func(i int, url string, n Notification) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = s.Sender.Send(sendCtx, url, Event, n)
+		}(i, c.r.Webhook, n)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			deliveries[i].Error = err.Error()
+			failed = append(failed, claimed[i].id)
+		}
+	}
+
+	if len(failed) > 0 {
+		// Unmark even when ctx is done, or the deliveries are never retried.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), unmarkTimeout)
+		defer cancel()
+		err := s.updateSent(ctx, func(sent map[string]time.Time) {
+			for _, id := range failed {
+				delete(sent, id)
+			}
+		})
+		if err != nil {
+			return deliveries, fmt.Errorf("saving sent reminders: %w", err)
+		}
+	}
+	return deliveries, nil
+}
+
+// updateSent changes the sent markers atomically.
+func (s *Scheduler) updateSent(ctx context.Context, fn func(sent map[string]time.Time)) error {
+	return s.Store.Update(ctx, sentKey, func(old []byte) ([]byte, error) {
+		sent := map[string]time.Time{}
+		if old != nil {
+			if err := json.Unmarshal(old, &sent); err != nil {
+				return nil, err
+			}
+		}
+		fn(sent)
+		return json.Marshal(sent)
+	})
+}
+
+// Loop calls Run with the channels from load every interval until ctx is
+// done, for deployments with a long-running process instead of cron jobs.
+func (s *Scheduler) Loop(ctx context.Context, interval time.Duration, load func(context.Context) ([]source.Channel, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		channels, err := load(ctx)
+		if err != nil {
+			logging.From(ctx).Error("loading channels for reminders failed", "error", err)
+		} else if deliveries, err := s.Run(ctx, channels); err != nil {
+			logging.From(ctx).Error("running reminders failed", "error", err)
+		} else {
+			for _, d := range deliveries {
+				if d.Error != "" {
+					logging.From(ctx).Warn("sending reminder failed", "reminder_id", d.ReminderID, "error", d.Error)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/webhook"
+)
+
+// receiver records the notifications posted to it, fails while down and
+// does not answer while hung.
+type receiver struct {
+	mu   sync.Mutex
+	got  []Notification
+	down bool
+	hung bool
+	// started gets a value, if there is room, for each request received
+	// while hung.
+	started chan struct{}
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.hung {
+		select {
+		case rc.started <- struct{}{}:
+		default:
+		}
+		rc.mu.Unlock()
+		// The server notices the client going away once the body is read.
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		rc.mu.Lock()
+		return
+	}
+	if rc.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var n Notification
+	json.NewDecoder(r.Body).Decode(&n)
+	rc.got = append(rc.got, n)
+}
+
+func (rc *receiver) take() []Notification {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	got := rc.got
+	rc.got = nil
+	return got
+}
+
+var start = time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)
+
+func setup(t *testing.T, reminders ...*Reminder) (*Scheduler, *receiver, *time.Time) {
+	t.Helper()
+	rc := &receiver{started: make(chan struct{}, 1)}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	// Reminders must name public hosts, so the client dials the test
+	// server whatever the host.
+	client := srv.Client()
+	client.Transport = &http.Transport{DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}}
+
+	s := store.NewMemory()
+	err := Update(context.Background(), s, func(set Set) error {
+		for _, r := range reminders {
+			r.Webhook = "http://hooks.example.com/remind"
+			set[r.ID] = r
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := start.Add(-time.Hour)
+	sched := &Scheduler{
+		Store:  s,
+		Sender: &webhook.Sender{Client: client, Secret: "secret"},
+		Now:    func() time.Time { return now },
+	}
+	return sched, rc, &now
+}
+
+var channels = []source.Channel{{ID: "tvj", Name: "TVJ", Programmes: []source.Programme{
+	{Title: "Prime Time News", Start: start, End: start.Add(time.Hour)},
+	{Title: "Weather", Start: start.Add(time.Hour), End: start.Add(90 * time.Minute)},
+}}}
+
+func run(t *testing.T, sched *Scheduler) []Delivery {
+	t.Helper()
+	d, err := sched.Run(context.Background(), channels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestRunLead(t *testing.T) {
+	sched, rc, now := setup(t, &Reminder{ID: "news", Title: "news", Lead: 10})
+
+	*now = start.Add(-11 * time.Minute)
+	if d := run(t, sched); len(d) != 0 {
+		t.Errorf("before the lead: got %+v", d)
+	}
+	*now = start.Add(-10 * time.Minute)
+	run(t, sched)
+	got := rc.take()
+	if len(got) != 1 || got[0].Programme.Title != "Prime Time News" || got[0].StartsIn != 10 {
+		t.Fatalf("at the lead: got %+v", got)
+	}
+	*now = start.Add(-5 * time.Minute)
+	if d := run(t, sched); len(d) != 0 {
+		t.Errorf("second run: sent again %+v", d)
+	}
+}
+
+func TestRunLeadZero(t *testing.T) {
+	sched, rc, now := setup(t, &Reminder{ID: "news", Title: "news", Lead: 0})
+
+	*now = start.Add(-time.Minute)
+	run(t, sched)
+	*now = start
+	run(t, sched)
+	if got := rc.take(); len(got) != 1 || got[0].StartsIn != 0 {
+		t.Errorf("lead 0 at the start: got %+v", got)
+	}
+}
+
+func TestRunLateRun(t *testing.T) {
+	// A 2 minute lead with runs every 5 minutes: the run after the start
+	// still sends it, while the programme is on.
+	sched, rc, now := setup(t, &Reminder{ID: "news", Title: "news", Lead: 2})
+
+	*now = start.Add(-5 * time.Minute)
+	run(t, sched)
+	*now = start.Add(3 * time.Minute)
+	run(t, sched)
+	if got := rc.take(); len(got) != 1 || got[0].StartsIn != -3 {
+		t.Errorf("late run: got %+v", got)
+	}
+
+	*now = start.Add(2 * time.Hour)
+	sched2, rc2, now2 := setup(t, &Reminder{ID: "news", Title: "news", Lead: 2})
+	*now2 = *now
+	run(t, sched2)
+	if got := rc2.take(); len(got) != 0 {
+		t.Errorf("after the programme ended: got %+v", got)
+	}
+}
+
+func TestRunRetriesFailures(t *testing.T) {
+	sched, rc, now := setup(t, &Reminder{ID: "news", Title: "news", Lead: 10})
+	*now = start.Add(-10 * time.Minute)
+
+	rc.down = true
+	if d := run(t, sched); len(d) != 1 || d[0].Error == "" {
+		t.Fatalf("receiver down: got %+v", d)
+	}
+	rc.down = false
+	*now = start.Add(-5 * time.Minute)
+	if d := run(t, sched); len(d) != 1 || d[0].Error != "" {
+		t.Errorf("retry: got %+v", d)
+	}
+	if got := rc.take(); len(got) != 1 {
+		t.Errorf("retry: receiver got %d notifications, want 1", len(got))
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	sched, rc, now := setup(t, &Reminder{ID: "news", Title: "news", Lead: 10})
+	*now = start.Add(-10 * time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sched.Run(context.Background(), channels); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := rc.take(); len(got) != 1 {
+		t.Errorf("concurrent runs sent %d notifications, want 1", len(got))
+	}
+}
+
+func (rc *receiver) setHung(hung bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.hung = hung
+}
+
+// both matches the same programme as news, so a run has two deliveries.
+var both = []*Reminder{{ID: "news", Title: "news", Lead: 10}, {ID: "prime", Title: "prime", Lead: 10}}
+
+// retried checks that a run after a cut short one delivers every reminder.
+func retried(t *testing.T, sched *Scheduler, rc *receiver) {
+	t.Helper()
+	rc.setHung(false)
+	if d := run(t, sched); len(d) != 2 || d[0].Error != "" || d[1].Error != "" {
+		t.Errorf("next run: got %+v, want both reminders delivered", d)
+	}
+	if got := rc.take(); len(got) != 2 {
+		t.Errorf("next run: receiver got %d notifications, want 2", len(got))
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	sched, rc, now := setup(t, both...)
+	*now = start.Add(-10 * time.Minute)
+	sched.Concurrency = 1
+	rc.setHung(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan []Delivery)
+	go func() {
+		d, err := sched.Run(ctx, channels)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- d
+	}()
+
+	// Cancel while the first webhook is in flight and the second waits.
+	<-rc.started
+	cancel()
+	select {
+	case d := <-done:
+		if len(d) != 2 || d[0].Error == "" || d[1].Error == "" {
+			t.Errorf("cancelled run: got %+v, want both deliveries failed", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after its context was cancelled")
+	}
+	retried(t, sched, rc)
+}
+
+func TestRunBudget(t *testing.T) {
+	sched, rc, now := setup(t, both...)
+	*now = start.Add(-10 * time.Minute)
+	sched.Budget = 50 * time.Millisecond
+	rc.setHung(true)
+
+	began := time.Now()
+	d := run(t, sched)
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("Run took %s with a 50ms budget", elapsed)
+	}
+	if len(d) != 2 || d[0].Error == "" || d[1].Error == "" {
+		t.Errorf("over budget: got %+v, want both deliveries failed", d)
+	}
+	retried(t, sched, rc)
+}
+
+func TestBudgetBeforeDeadline(t *testing.T) {
+	sched := &Scheduler{}
+	if b := sched.budget(context.Background()); b != DefaultBudget {
+		t.Errorf("without a deadline: got %s, want %s", b, DefaultBudget)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if b := sched.budget(ctx); b > 10*time.Second-unmarkTimeout {
+		t.Errorf("10s deadline: got %s, want time left to unmark", b)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://hooks.example.com/remind": true,
+		"http://127.0.0.1:8080/":           false,
+		"http://169.254.169.254/latest":    false,
+		"file:///etc/passwd":               false,
+	} {
+		r := &Reminder{Title: "news", Webhook: raw}
+		if err := r.Validate(); (err == nil) != ok {
+			t.Errorf("webhook %q: got %v, want ok=%t", raw, err, ok)
+		}
+	}
+}
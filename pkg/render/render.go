@@ -0,0 +1,108 @@
+// Package render renders HTML pages from embedded templates.
+//
+// Every page in templates/pages is parsed together with the layouts and
+// partials, and is rendered through the "base" layout. A page defines the
+// "title" and "content" blocks, and may define "head" for extra styles.
+// Pages are executed into a buffer first, so a template error produces a
+// clean error page instead of half a document.
+package render
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/logging"
+)
+
+//go:embed templates
+var files embed.FS
+
+// ContentType is the media type of rendered pages.
+const ContentType = "text/html; charset=utf-8"
+
+// Funcs are available to every template.
+var Funcs = template.FuncMap{
+	"clock": func(t time.Time) string { return t.Format("15:04") },
+	"date":  func(t time.Time) string { return t.Format("Mon 2 Jan") },
+	"iso":   func(t time.Time) string { return t.Format(time.RFC3339) },
+	"add":   func(a, b int) int { return a + b },
+	// percent formats a number for use as a CSS percentage.
+	"percent": func(f float64) template.CSS { return template.CSS(strconv.FormatFloat(f, 'f', 4, 64) + "%") },
+	"link":    func(label, url string) Link { return Link{Label: label, URL: url} },
+}
+
+// Link is the data of the "copy-url" partial.
+type Link struct {
+	Label string
+	URL   string
+}
+
+var pages = mustParse()
+
+func mustParse() map[string]*template.Template {
+	shared := template.Must(template.New("").Funcs(Funcs).ParseFS(files,
+		"templates/layouts/*.html", "templates/partials/*.html"))
+
+	names, err := fs.Glob(files, "templates/pages/*.html")
+	if err != nil {
+		panic(err)
+	}
+	out := map[string]*template.Template{}
+	for _, name := range names {
+		t := template.Must(template.Must(shared.Clone()).ParseFS(files, name))
+		out[strings.TrimSuffix(path.Base(name), ".html")] = t
+	}
+	return out
+}
+
+// HTML renders the named page with data and writes it with status. Failures
+// are logged with the logger of r.
+func HTML(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := execute(&buf, name, data); err != nil {
+		logging.From(r.Context()).Error("rendering page failed", "page", name, "error", err)
+		Error(w, r, http.StatusInternalServerError, "The page could not be rendered.")
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+func execute(buf *bytes.Buffer, name string, data interface{}) error {
+	t, ok := pages[name]
+	if !ok {
+		return fmt.Errorf("no page named %q", name)
+	}
+	return t.ExecuteTemplate(buf, "base", data)
+}
+
+// ErrorPage is the data of the "error" page.
+type ErrorPage struct {
+	Status  int
+	Title   string
+	Message string
+}
+
+// Error renders the error page for status with message.
+func Error(w http.ResponseWriter, r *http.Request, status int, message string) {
+	var buf bytes.Buffer
+	data := ErrorPage{Status: status, Title: http.StatusText(status), Message: message}
+	if err := execute(&buf, "error", data); err != nil {
+		logging.From(r.Context()).Error("rendering error page failed", "error", err)
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
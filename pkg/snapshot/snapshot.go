@@ -0,0 +1,78 @@
+// Package snapshot keeps the last successfully generated playlist and guide
+// so they can be served when the upstream feed is unavailable.
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+	"template-go-vercel/pkg/logging"
+	"template-go-vercel/pkg/store"
+)
+
+const keyPrefix = "snapshot:"
+
+// Snapshot is a stored copy of a generated document.
+type Snapshot struct {
+	Data []byte `json:"data"`
+	// Hash is the SHA-256 of Data, to tell unchanged documents apart
+	// without comparing them.
+	Hash string `json:"hash,omitempty"`
+	// SavedAt is when this content was first saved.
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// Save stores data as the last-known-good snapshot called name. The store
+// is only written when data differs from the current snapshot, so serving
+// the same document again costs a read, not a write.
+func Save(ctx context.Context, s store.Store, name string, data []byte) error {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	return s.Update(ctx, keyPrefix+name, func(old []byte) ([]byte, error) {
+		var prev Snapshot
+		if old != nil && json.Unmarshal(old, &prev) == nil && prev.Hash == hash {
+			return nil, nil
+		}
+		return json.Marshal(Snapshot{Data: data, Hash: hash, SavedAt: time.Now().UTC()})
+	})
+}
+
+// Load returns the snapshot called name, or store.ErrNotFound.
+func Load(ctx context.Context, s store.Store, name string) (*Snapshot, error) {
+	b, err := s.Get(ctx, keyPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// ServeStale answers a request whose live document could not be built
+// because what failed with err. When a snapshot called name exists it is
+// written with a 200 status and a stale Warning header; otherwise the
+// request fails as fetch.Fail does.
+func ServeStale(w http.ResponseWriter, r *http.Request, s store.Store, name, contentType, what string, err error) {
+	logging.From(r.Context()).Warn("serving snapshot", "snapshot", name, "cause", err)
+	snap, loadErr := Load(r.Context(), s, name)
+	if loadErr != nil {
+		if loadErr != store.ErrNotFound {
+			logging.From(r.Context()).Error("loading snapshot failed", "snapshot", name, "error", loadErr)
+		}
+		fetch.Fail(w, r, what, err)
+		return
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	w.Header().Set("X-Snapshot-Saved-At", snap.SavedAt.Format(time.RFC3339))
+	w.Write(snap.Data)
+}
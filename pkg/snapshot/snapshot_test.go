@@ -0,0 +1,67 @@
+package snapshot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"template-go-vercel/pkg/store"
+)
+
+// countingStore counts the writes to a Memory store.
+type countingStore struct {
+	*store.Memory
+	writes int
+}
+
+func (s *countingStore) Update(ctx context.Context, key string, fn store.UpdateFunc) error {
+	return s.Memory.Update(ctx, key, func(old []byte) ([]byte, error) {
+		b, err := fn(old)
+		if b != nil {
+			s.writes++
+		}
+		return b, err
+	})
+}
+
+func TestSaveOnlyWritesChanges(t *testing.T) {
+	ctx := context.Background()
+	s := &countingStore{Memory: store.NewMemory()}
+
+	for i := 0; i < 3; i++ {
+		if err := Save(ctx, s, "m3u:free", []byte("#EXTM3U\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.writes != 1 {
+		t.Errorf("same playlist saved 3 times: got %d writes, want 1", s.writes)
+	}
+	if err := Save(ctx, s, "m3u:free", []byte("#EXTM3U\n#EXTINF:-1,TVJ\n")); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := Load(ctx, s, "m3u:free")
+	if err != nil || s.writes != 2 || string(snap.Data) != "#EXTM3U\n#EXTINF:-1,TVJ\n" {
+		t.Errorf("changed playlist: got %d writes and %+v, %v", s.writes, snap, err)
+	}
+}
+
+func TestServeStale(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := Save(ctx, s, "m3u:free:logos", []byte("#EXTM3U\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	ServeStale(w, httptest.NewRequest(http.MethodGet, "/", nil), s, "m3u:free:logos", "audio/x-mpegurl", "loading channels", context.DeadlineExceeded)
+	if w.Code != http.StatusOK || w.Body.String() != "#EXTM3U\n" || w.Header().Get("Warning") == "" {
+		t.Errorf("saved variant: got %d %q", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	ServeStale(w, httptest.NewRequest(http.MethodGet, "/", nil), s, "m3u:free", "audio/x-mpegurl", "loading channels", context.DeadlineExceeded)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("other variant: got %d, want 504 rather than the saved variant", w.Code)
+	}
+}
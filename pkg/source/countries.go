@@ -0,0 +1,67 @@
+package source
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Countries is a list of ISO 3166-1 alpha-2 country codes. It decodes the
+// shapes feeds use for allowed-country lists: null, a single string of
+// comma or space separated codes, an array of codes, or an array of
+// objects with a code or country field. "all", "*" and "ww" mean no
+// restriction and decode to an empty list.
+type Countries []string
+
+func (c *Countries) UnmarshalJSON(b []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var codes []string
+	switch v := raw.(type) {
+	case string:
+		codes = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' || r == ';' })
+	case []interface{}:
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				codes = append(codes, item)
+			case map[string]interface{}:
+				for _, key := range []string{"code", "country", "iso"} {
+					if s, ok := item[key].(string); ok {
+						codes = append(codes, s)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	*c = nil
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		switch code {
+		case "":
+			continue
+		case "ALL", "*", "WW":
+			*c = nil
+			return nil
+		}
+		*c = append(*c, code)
+	}
+	return nil
+}
+
+// Allows reports whether country may play the channel. An empty list
+// allows everyone, as does an unknown caller country.
+func (c Countries) Allows(country string) bool {
+	if len(c) == 0 || country == "" {
+		return true
+	}
+	for _, code := range c {
+		if strings.EqualFold(code, country) {
+			return true
+		}
+	}
+	return false
+}
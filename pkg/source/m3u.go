@@ -0,0 +1,107 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"template-go-vercel/pkg/fetch"
+)
+
+// M3U reads a plain #EXTM3U playlist.
+type M3U struct {
+	URL     string
+	Fetcher *fetch.Fetcher
+}
+
+func (s *M3U) Channels(ctx context.Context) ([]Channel, error) {
+	body, err := s.Fetcher.Get(ctx, s.URL)
+	if err != nil {
+		return nil, err
+	}
+	return ParseM3U(body)
+}
+
+var extinfAttr = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+
+// ParseM3U reads the #EXTINF entries of a playlist. Channels without a
+// tvg-id are identified by their name.
+func ParseM3U(b []byte) ([]Channel, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var channels []Channel
+	var pending *Channel
+	header := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !header {
+			if !strings.HasPrefix(line, "#EXTM3U") {
+				return nil, &ParseError{Format: "m3u", Err: errors.New("missing #EXTM3U header")}
+			}
+			header = true
+			continue
+		}
+		if strings.HasPrefix(line, "#EXTINF:") {
+			pending = parseEXTINF(line)
+			continue
+		}
+		if strings.HasPrefix(line, "#") || pending == nil {
+			continue
+		}
+		pending.StreamURL = line
+		if pending.ID == "" {
+			pending.ID = pending.Name
+		}
+		channels = append(channels, *pending)
+		pending = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{Format: "m3u", Err: err}
+	}
+	if !header {
+		return nil, &ParseError{Format: "m3u", Err: errors.New("missing #EXTM3U header")}
+	}
+	return channels, nil
+}
+
+func parseEXTINF(line string) *Channel {
+	info := strings.TrimPrefix(line, "#EXTINF:")
+	attrs, title := info, ""
+	// The title follows the last comma that is not inside a quoted value.
+	inQuote := false
+	for i, r := range info {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case r == ',' && !inQuote:
+			attrs, title = info[:i], info[i+1:]
+		}
+	}
+
+	ch := &Channel{Name: strings.TrimSpace(title)}
+	for _, m := range extinfAttr.FindAllStringSubmatch(attrs, -1) {
+		switch m[1] {
+		case "tvg-id":
+			ch.ID = m[2]
+		case "tvg-name":
+			if ch.Name == "" {
+				ch.Name = m[2]
+			}
+		case "tvg-logo":
+			ch.Logo = m[2]
+		case "group-title":
+			ch.Group = m[2]
+		case "tvg-chno":
+			ch.Number, _ = strconv.Atoi(m[2])
+		}
+	}
+	return ch
+}
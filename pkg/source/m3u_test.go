@@ -0,0 +1,46 @@
+package source
+
+import (
+	"testing"
+)
+
+func TestParseM3U(t *testing.T) {
+	channels, err := ParseM3U(fixture(t, "playlist.m3u"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Channel{
+		{
+			ID:        "tvj",
+			Name:      "TVJ",
+			Group:     "News, Local",
+			Number:    5,
+			Logo:      "https://img.example.com/tvj.png",
+			StreamURL: "https://cdn.example.com/tvj.m3u8",
+		},
+		{
+			ID:        "Radio Jamaica",
+			Name:      "Radio Jamaica",
+			Group:     "Radio",
+			StreamURL: "https://cdn.example.com/rjr.m3u8",
+		},
+	}
+	if len(channels) != len(want) {
+		t.Fatalf("got %d channels, want %d: %+v", len(channels), len(want), channels)
+	}
+	for i := range want {
+		got := channels[i]
+		if got.ID != want[i].ID || got.Name != want[i].Name || got.Group != want[i].Group ||
+			got.Number != want[i].Number || got.Logo != want[i].Logo || got.StreamURL != want[i].StreamURL {
+			t.Errorf("channel %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestParseM3UMissingHeader(t *testing.T) {
+	for _, body := range []string{"", "#EXTINF:-1,TVJ\nhttps://cdn.example.com/tvj.m3u8\n"} {
+		if _, err := ParseM3U([]byte(body)); err == nil {
+			t.Errorf("%q: want error", body)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+)
+
+// OneSpot reads the 1spotmedia live streams JSON feed.
+type OneSpot struct {
+	URL     string
+	Fetcher *fetch.Fetcher
+}
+
+func (s *OneSpot) Channels(ctx context.Context) ([]Channel, error) {
+	body, err := s.Fetcher.Get(ctx, s.URL)
+	if err != nil {
+		return nil, err
+	}
+	return ParseOneSpot(body)
+}
+
+// ParseOneSpot decodes a 1spotmedia feed and normalizes its channels.
+func ParseOneSpot(b []byte) ([]Channel, error) {
+	var feed []OneSpotChannel
+	if err := json.Unmarshal(b, &feed); err != nil {
+		return nil, &ParseError{Format: "1spotmedia", Err: err}
+	}
+	channels := make([]Channel, len(feed))
+	for i, ch := range feed {
+		channels[i] = ch.Normalize()
+	}
+	return channels, nil
+}
+
+// OneSpotStream is a stream or image reference in the 1spotmedia feed.
+type OneSpotStream struct {
+	DownloadURL  string `json:"downloadUrl"`
+	StreamingURL string `json:"streamingUrl"`
+	URL          string `json:"url"`
+}
+
+// OneSpotChannel is the structure of a channel object in the 1spotmedia feed.
+type OneSpotChannel struct {
+	VodCategory      []interface{} `json:"vod_category"`
+	Categories       []interface{} `json:"categories"`
+	ID               string        `json:"_id"`
+	Title            string        `json:"title"`
+	SeriesID         string        `json:"series_id"`
+	AiredDate        int64         `json:"aired_date"`
+	AllowedCountries Countries     `json:"allowedCountries"`
+	AdPolicyID       interface{}   `json:"adPolicyId"`
+	Epg              struct {
+		Events []struct {
+			Title  string    `json:"title"`
+			Start  time.Time `json:"start"`
+			End    time.Time `json:"end"`
+			Custom struct {
+				Duration int    `json:"duration"`
+				Rating   string `json:"rating"`
+				Image    struct {
+					Width       string `json:"width"`
+					Height      string `json:"height"`
+					DownloadURL string `json:"downloadUrl"`
+				} `json:"image"`
+			} `json:"custom"`
+		} `json:"events"`
+	} `json:"epg"`
+	Rating                  string        `json:"rating"`
+	MediaType               string        `json:"mediaType"`
+	Order                   int           `json:"order"`
+	HLSStream               OneSpotStream `json:"HLSStream"`
+	CommerceType            string        `json:"commerceType,omitempty"`
+	PaidType                string        `json:"paidType,omitempty"`
+	SubscriptionsCategories []string      `json:"subscriptionsCategories,omitempty"`
+	PosterH                 struct {
+		DownloadURL string `json:"downloadUrl"`
+	} `json:"PosterH"`
+	AndroidStream        OneSpotStream `json:"AndroidStream"`
+	AndroidBlockedStream OneSpotStream `json:"AndroidBlockedStream"`
+	HLSBlockedStream     OneSpotStream `json:"HLSBlockedStream"`
+	LogoLarge            string        `json:"logoLarge"`
+	ChannelLogoLarge     OneSpotStream `json:"ChannelLogoLarge"`
+	ChannelLogoTablets   OneSpotStream `json:"ChannelLogoTablets"`
+	PosterF              struct {
+		DownloadURL string `json:"downloadUrl"`
+	} `json:"PosterF,omitempty"`
+}
+
+// Normalize converts the feed's channel into the shared Channel model.
+func (c OneSpotChannel) Normalize() Channel {
+	ch := Channel{
+		ID:         c.ID,
+		Name:       c.Title,
+		Order:      c.Order,
+		Logo:       c.ChannelLogoTablets.DownloadURL,
+		StreamURL:  c.AndroidStream.StreamingURL,
+		GuideURL:   c.HLSStream.StreamingURL,
+		MediaType:  c.MediaType,
+		SeriesID:   c.SeriesID,
+		AiredAt:    unixTime(c.AiredDate),
+		Poster:     c.PosterF.DownloadURL,
+		PosterWide: c.PosterH.DownloadURL,
+
+		PaidType:                c.PaidType,
+		CommerceType:            c.CommerceType,
+		SubscriptionsCategories: c.SubscriptionsCategories,
+		AllowedCountries:        c.AllowedCountries,
+		AdPolicyID:              stringValue(c.AdPolicyID),
+	}
+	ch.BlockedStreamURL = c.AndroidBlockedStream.StreamingURL
+	if ch.BlockedStreamURL == "" {
+		ch.BlockedStreamURL = c.HLSBlockedStream.StreamingURL
+	}
+	for _, logo := range []string{c.ChannelLogoLarge.DownloadURL, c.LogoLarge, c.ChannelLogoTablets.DownloadURL} {
+		if logo != "" {
+			ch.Logos = append(ch.Logos, logo)
+		}
+	}
+	if len(c.VodCategory) > 0 {
+		ch.Category, _ = c.VodCategory[0].(string)
+	} else if len(c.Categories) > 0 {
+		ch.Category, _ = c.Categories[0].(string)
+	}
+	for _, event := range c.Epg.Events {
+		ch.Programmes = append(ch.Programmes, Programme{
+			Title:    event.Title,
+			Start:    event.Start,
+			End:      event.End,
+			Duration: event.Custom.Duration,
+			Rating:   event.Custom.Rating,
+			Image:    event.Custom.Image.DownloadURL,
+		})
+	}
+	return ch
+}
+
+// unixTime converts a feed timestamp, in seconds or milliseconds, to a time.
+func unixTime(ts int64) time.Time {
+	switch {
+	case ts <= 0:
+		return time.Time{}
+	case ts > 1e12:
+		return time.UnixMilli(ts).UTC()
+	default:
+		return time.Unix(ts, 0).UTC()
+	}
+}
+
+// stringValue converts a decoded JSON string or number.
+func stringValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+)
+
+func TestParseOneSpot(t *testing.T) {
+	channels, err := ParseOneSpot(fixture(t, "onespot.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 2 {
+		t.Fatalf("got %d channels, want 2", len(channels))
+	}
+
+	live := channels[0]
+	if live.ID != "tvj" || live.Name != "TVJ" || live.Order != 1 || live.Category != "News" {
+		t.Errorf("live channel = %+v", live)
+	}
+	if live.StreamURL != "https://cdn.example.com/tvj/android.m3u8" {
+		t.Errorf("StreamURL = %q, want the Android stream", live.StreamURL)
+	}
+	if live.GuideURL != "https://cdn.example.com/tvj/hls.m3u8" {
+		t.Errorf("GuideURL = %q, want the HLS stream", live.GuideURL)
+	}
+	if live.BlockedStreamURL != "https://cdn.example.com/blocked.m3u8" {
+		t.Errorf("BlockedStreamURL = %q, want the HLS blocked stream", live.BlockedStreamURL)
+	}
+	if live.Logo != "https://img.example.com/tvj-tablet.png" {
+		t.Errorf("Logo = %q", live.Logo)
+	}
+	wantLogos := []string{"https://img.example.com/tvj-large.png", "https://img.example.com/tvj-tablet.png"}
+	if !reflect.DeepEqual(live.Logos, wantLogos) {
+		t.Errorf("Logos = %q, want %q", live.Logos, wantLogos)
+	}
+	if !reflect.DeepEqual(live.AllowedCountries, Countries{"JM", "US"}) {
+		t.Errorf("AllowedCountries = %q", live.AllowedCountries)
+	}
+	if live.AdPolicyID != "42" {
+		t.Errorf("AdPolicyID = %q, want 42", live.AdPolicyID)
+	}
+	wantProg := Programme{
+		Title:    "Prime Time News",
+		Start:    time.Date(2026, 10, 18, 19, 0, 0, 0, time.UTC),
+		End:      time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC),
+		Duration: 60,
+		Rating:   "PG",
+		Image:    "https://img.example.com/news.jpg",
+	}
+	if len(live.Programmes) != 1 || !reflect.DeepEqual(live.Programmes[0], wantProg) {
+		t.Errorf("Programmes = %+v, want [%+v]", live.Programmes, wantProg)
+	}
+
+	ep := channels[1]
+	if ep.IsLive() || ep.SeriesID != "smile" || ep.Category != "Morning" {
+		t.Errorf("episode = %+v", ep)
+	}
+	if want := time.UnixMilli(1760770800000).UTC(); !ep.AiredAt.Equal(want) {
+		t.Errorf("AiredAt = %v, want %v", ep.AiredAt, want)
+	}
+	if ep.Poster != "https://img.example.com/smile-f.jpg" || ep.PosterWide != "https://img.example.com/smile-h.jpg" {
+		t.Errorf("posters = %q, %q", ep.Poster, ep.PosterWide)
+	}
+}
+
+func TestParseOneSpotInvalid(t *testing.T) {
+	_, err := ParseOneSpot([]byte(`{"not": "a list"}`))
+	if _, ok := err.(*ParseError); !ok {
+		t.Fatalf("got %v, want *ParseError", err)
+	}
+}
+
+func TestOneSpotChannels(t *testing.T) {
+	body := fixture(t, "onespot.json")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	src := &OneSpot{URL: srv.URL, Fetcher: fetch.JSON()}
+	channels, err := src.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 2 {
+		t.Errorf("got %d channels, want 2", len(channels))
+	}
+}
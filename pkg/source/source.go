@@ -0,0 +1,164 @@
+// Package source normalizes upstream channel feeds into one channel model.
+//
+// Each supported upstream format has an adapter implementing Source. The
+// adapter is picked with SOURCE_TYPE and reads from MEDIA_URL:
+//
+//	1spotmedia (default)  the 1spotmedia live streams JSON feed
+//	m3u                   a plain #EXTM3U playlist
+//	xtream                an Xtream Codes player_api.php URL, including
+//	                      the username and password query parameters
+//	static                a JSON or YAML list of Channel objects, over
+//	                      HTTP or from a local file path
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/fetch"
+)
+
+// ErrNotConfigured is returned by FromEnv when MEDIA_URL is not set.
+var ErrNotConfigured = errors.New("MEDIA_URL environment variable is not set")
+
+// DefaultGroup is the group of channels whose source does not provide one.
+const DefaultGroup = "TVJ"
+
+// Channel is the provider-independent channel model consumed by the
+// playlist and guide generators.
+type Channel struct {
+	ID string `json:"id"`
+	// GuideID is the channel's id in the guide (tvg-id) when it differs
+	// from ID, which must be unique. Variants of a channel, such as HD and
+	// SD streams, may share one.
+	GuideID string `json:"guide_id,omitempty"`
+	Name    string `json:"name"`
+	Group   string `json:"group,omitempty"`
+	Number  int    `json:"number,omitempty"`
+	Order   int    `json:"order,omitempty"`
+	Logo    string `json:"logo,omitempty"`
+	// Logos lists alternative logo URLs, best first.
+	Logos     []string `json:"logos,omitempty"`
+	StreamURL string   `json:"url"`
+	// GuideURL is the URL listed for the channel in the XMLTV guide when
+	// it differs from StreamURL.
+	GuideURL string `json:"guide_url,omitempty"`
+	Category string `json:"category,omitempty"`
+	// MediaType is the upstream media type; see IsLive.
+	MediaType string `json:"media_type,omitempty"`
+	// SeriesID and AiredAt identify on-demand episodes.
+	SeriesID string    `json:"series_id,omitempty"`
+	AiredAt  time.Time `json:"aired_at,omitempty"`
+	// Poster is portrait artwork and PosterWide landscape artwork.
+	Poster     string `json:"poster,omitempty"`
+	PosterWide string `json:"poster_wide,omitempty"`
+	// BlockedStreamURL is the stream handed to callers not entitled to the
+	// channel, typically a slate or preview.
+	BlockedStreamURL string `json:"blocked_url,omitempty"`
+	// Entitlement data, see package policy.
+	PaidType                string    `json:"paid_type,omitempty"`
+	CommerceType            string    `json:"commerce_type,omitempty"`
+	SubscriptionsCategories []string  `json:"subscriptions_categories,omitempty"`
+	AllowedCountries        Countries `json:"allowed_countries,omitempty"`
+	AdPolicyID              string    `json:"ad_policy_id,omitempty"`
+
+	Programmes []Programme `json:"programmes,omitempty"`
+}
+
+// EPGID returns the id the channel is listed under in guides.
+func (c Channel) EPGID() string {
+	if c.GuideID != "" {
+		return c.GuideID
+	}
+	return c.ID
+}
+
+// GroupName returns the channel's group, or DefaultGroup when it has none.
+func (c Channel) GroupName() string {
+	if c.Group == "" {
+		return DefaultGroup
+	}
+	return c.Group
+}
+
+// defaultLiveMediaTypes are the media types treated as live channels when
+// LIVE_MEDIA_TYPES is not set.
+const defaultLiveMediaTypes = "live,channel,linear,tv"
+
+// IsLive reports whether the channel is a linear channel rather than an
+// on-demand item. Items without a media type are live; otherwise the media
+// type must be one of the comma separated LIVE_MEDIA_TYPES.
+func (c Channel) IsLive() bool {
+	if c.MediaType == "" {
+		return true
+	}
+	types := os.Getenv("LIVE_MEDIA_TYPES")
+	if types == "" {
+		types = defaultLiveMediaTypes
+	}
+	for _, t := range strings.Split(types, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), c.MediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Programme is a single EPG entry.
+type Programme struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Duration    int       `json:"duration,omitempty"` // minutes
+	Rating      string    `json:"rating,omitempty"`
+	Image       string    `json:"image,omitempty"`
+}
+
+// Source loads the current channel list from an upstream.
+type Source interface {
+	Channels(ctx context.Context) ([]Channel, error)
+}
+
+// ParseError is returned when an upstream response cannot be decoded.
+type ParseError struct {
+	Format string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parsing %s feed: %v", e.Format, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// FromEnv returns the Source selected by SOURCE_TYPE for MEDIA_URL, using
+// the "media" fetcher settings.
+func FromEnv() (Source, error) {
+	mediaURL := os.Getenv("MEDIA_URL")
+	if mediaURL == "" {
+		return nil, ErrNotConfigured
+	}
+	f, err := fetch.Media()
+	if err != nil {
+		return nil, err
+	}
+	switch kind := strings.ToLower(os.Getenv("SOURCE_TYPE")); kind {
+	case "", "1spotmedia", "json":
+		return &OneSpot{URL: mediaURL, Fetcher: f}, nil
+	case "m3u":
+		return &M3U{URL: mediaURL, Fetcher: f}, nil
+	case "xtream":
+		return &Xtream{URL: mediaURL, Fetcher: f}, nil
+	case "static":
+		return &Static{Path: mediaURL, Fetcher: f}, nil
+	default:
+		return nil, fmt.Errorf("unknown SOURCE_TYPE %q", kind)
+	}
+}
@@ -0,0 +1,47 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixture returns the contents of testdata/name.
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("MEDIA_URL", "https://example.com/feed")
+	for kind, want := range map[string]string{
+		"":           "*source.OneSpot",
+		"1spotmedia": "*source.OneSpot",
+		"m3u":        "*source.M3U",
+		"xtream":     "*source.Xtream",
+		"static":     "*source.Static",
+	} {
+		t.Setenv("SOURCE_TYPE", kind)
+		src, err := FromEnv()
+		if err != nil {
+			t.Fatalf("SOURCE_TYPE=%q: %v", kind, err)
+		}
+		if got := fmt.Sprintf("%T", src); got != want {
+			t.Errorf("SOURCE_TYPE=%q: got %s, want %s", kind, got, want)
+		}
+	}
+
+	t.Setenv("SOURCE_TYPE", "rss")
+	if _, err := FromEnv(); err == nil {
+		t.Error("unknown SOURCE_TYPE: want error")
+	}
+	t.Setenv("MEDIA_URL", "")
+	if _, err := FromEnv(); err != ErrNotConfigured {
+		t.Errorf("no MEDIA_URL: got %v, want ErrNotConfigured", err)
+	}
+}
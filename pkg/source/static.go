@@ -0,0 +1,57 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"template-go-vercel/pkg/fetch"
+)
+
+// Static reads a JSON or YAML list of Channel objects from a URL or a local
+// file. Both formats use the Channel JSON field names.
+type Static struct {
+	Path    string
+	Fetcher *fetch.Fetcher
+}
+
+func (s *Static) Channels(ctx context.Context) ([]Channel, error) {
+	var body []byte
+	var err error
+	if strings.HasPrefix(s.Path, "http://") || strings.HasPrefix(s.Path, "https://") {
+		body, err = s.Fetcher.Get(ctx, s.Path)
+	} else {
+		body, err = os.ReadFile(strings.TrimPrefix(s.Path, "file://"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatic(body)
+}
+
+// ParseStatic decodes a JSON or YAML channel list. Anything that does not
+// start with "[" is read as YAML, which is a superset of JSON.
+func ParseStatic(b []byte) ([]Channel, error) {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) == 0 || trimmed[0] != '[' {
+		var doc interface{}
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, &ParseError{Format: "static", Err: err}
+		}
+		// Round-trip through JSON so the Channel JSON field names and
+		// decoders apply to YAML lists too.
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return nil, &ParseError{Format: "static", Err: err}
+		}
+		b = converted
+	}
+	var channels []Channel
+	if err := json.Unmarshal(b, &channels); err != nil {
+		return nil, &ParseError{Format: "static", Err: err}
+	}
+	return channels, nil
+}
@@ -0,0 +1,54 @@
+package source
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStatic(t *testing.T) {
+	want := []Channel{{
+		ID:        "tvj",
+		Name:      "TVJ",
+		Group:     "News",
+		Number:    5,
+		StreamURL: "https://cdn.example.com/tvj.m3u8",
+		Programmes: []Programme{{
+			Title: "News",
+			Start: time.Date(2026, 10, 18, 19, 0, 0, 0, time.UTC),
+			End:   time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC),
+		}},
+	}}
+	for _, name := range []string{"static.json", "static.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			channels, err := ParseStatic(fixture(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(channels, want) {
+				t.Errorf("got %+v, want %+v", channels, want)
+			}
+		})
+	}
+}
+
+func TestParseStaticInvalid(t *testing.T) {
+	for _, body := range []string{"[{", "id: [", "just a string"} {
+		if _, err := ParseStatic([]byte(body)); err == nil {
+			t.Errorf("%q: want error", body)
+		}
+	}
+}
+
+func TestStaticChannelsFromFile(t *testing.T) {
+	src := &Static{Path: filepath.Join("testdata", "static.yaml")}
+	channels, err := src.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 1 || channels[0].ID != "tvj" {
+		t.Errorf("channels = %+v", channels)
+	}
+}
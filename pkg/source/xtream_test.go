@@ -0,0 +1,71 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"template-go-vercel/pkg/fetch"
+)
+
+func TestParseXtream(t *testing.T) {
+	api, _ := url.Parse("http://panel.example.com:8080/player_api.php?username=bob&password=p%2Fss")
+	channels, err := ParseXtream(api, fixture(t, "xtream_categories.json"), fixture(t, "xtream_streams.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 3 {
+		t.Fatalf("got %d channels, want 3", len(channels))
+	}
+
+	tvj := channels[0]
+	if tvj.ID != "101" || tvj.EPGID() != "tvj" || tvj.Name != "TVJ" || tvj.Group != "News" || tvj.Number != 1 {
+		t.Errorf("channel 0 = %+v", tvj)
+	}
+	if want := "http://panel.example.com:8080/live/bob/p%2Fss/101.m3u8"; tvj.StreamURL != want {
+		t.Errorf("StreamURL = %q, want %q", tvj.StreamURL, want)
+	}
+
+	// Unknown categories leave the group empty, and without an EPG id the
+	// guide lists the channel under its stream id.
+	cvm := channels[1]
+	if cvm.ID != "102" || cvm.EPGID() != "102" || cvm.Group != "" {
+		t.Errorf("channel 1 = %+v", cvm)
+	}
+
+	// Variants sharing an EPG id stay separate channels with the same
+	// guide id.
+	sd := channels[2]
+	if sd.ID != "103" || sd.EPGID() != "tvj" || sd.StreamURL == tvj.StreamURL {
+		t.Errorf("channel 2 = %+v", sd)
+	}
+}
+
+func TestXtreamChannels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("username") != "bob" || r.URL.Query().Get("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("action") {
+		case "get_live_categories":
+			w.Write(fixture(t, "xtream_categories.json"))
+		case "get_live_streams":
+			w.Write(fixture(t, "xtream_streams.json"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	src := &Xtream{URL: srv.URL + "/player_api.php?username=bob&password=secret", Fetcher: fetch.JSON()}
+	channels, err := src.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 3 || channels[0].Group != "News" {
+		t.Errorf("channels = %+v", channels)
+	}
+}
@@ -0,0 +1,259 @@
+// Package store is a small key/value abstraction used to persist state
+// between invocations, backed by Redis or by files on local disk for
+// self-hosted deployments.
+package store
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"template-go-vercel/pkg/rdb"
+)
+
+// ErrNotFound is returned by Get when a key does not exist.
+var ErrNotFound = errors.New("store: key not found")
+
+// ErrNotDurable is reported by endpoints that refuse to save state, such
+// as revocations or overrides, that the memory store would lose.
+var ErrNotDurable = errors.New("store: no persistent store configured, set REDIS_URL or STORE_DIR")
+
+// ErrConflict is returned by Update when the value kept changing under it.
+var ErrConflict = errors.New("store: too many concurrent updates")
+
+// UpdateFunc computes a new value from the current one, which is nil when
+// the key does not exist. Returning a nil value leaves the key unchanged.
+// It may be called more than once and must not have side effects.
+type UpdateFunc func(old []byte) ([]byte, error)
+
+// Store persists opaque values by key.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte) error
+	// Update replaces the value of key with fn's result, atomically with
+	// respect to other Updates of the same key.
+	Update(ctx context.Context, key string, fn UpdateFunc) error
+}
+
+// maxAttempts bounds the optimistic retries of Redis.Update.
+const maxAttempts = 25
+
+// memory is the per-process store used when neither STORE_DIR nor Redis
+// is configured.
+var memory = NewMemory()
+
+// FromEnv returns a File store rooted at STORE_DIR when it is set, the
+// shared Redis client when REDIS_URL is set, and a per-process memory
+// store otherwise.
+func FromEnv() Store {
+	if dir := os.Getenv("STORE_DIR"); dir != "" {
+		return &File{Dir: dir}
+	}
+	if !rdb.Enabled() {
+		return memory
+	}
+	client, err := rdb.Client()
+	if err != nil {
+		slog.Warn("redis unavailable, using memory store", "error", err)
+		return memory
+	}
+	return &Redis{Client: client}
+}
+
+// Durable reports whether values saved in s outlive this function
+// instance. The memory store's values are lost on the next cold start and
+// are not seen by other instances.
+func Durable(s Store) bool {
+	_, mem := s.(*Memory)
+	return !mem
+}
+
+// Redis stores values as plain Redis strings.
+type Redis struct {
+	Client *redis.Client
+}
+
+func (s *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.Client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+func (s *Redis) Set(ctx context.Context, key string, value []byte) error {
+	return s.Client.Set(ctx, key, value, 0).Err()
+}
+
+// Update uses WATCH and MULTI, retrying when another client wrote the key
+// in between.
+func (s *Redis) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	for i := 0; i < maxAttempts; i++ {
+		err := s.Client.Watch(ctx, func(tx *redis.Tx) error {
+			old, err := tx.Get(ctx, key).Bytes()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			value, err := fn(old)
+			if err != nil || value == nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.Set(ctx, key, value, 0)
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+		// Back off for a random, growing delay so competing writers
+		// spread out.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(rand.Int63n(int64(i+1) * int64(2*time.Millisecond)))):
+		}
+	}
+	return ErrConflict
+}
+
+// File stores each key as a file in Dir.
+type File struct {
+	Dir string
+}
+
+func (s *File) path(key string) string {
+	return filepath.Join(s.Dir, url.PathEscape(key))
+}
+
+func (s *File) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+// Set writes value to a temporary file and renames it into place so that
+// readers never observe a partial write.
+func (s *File) Set(ctx context.Context, key string, value []byte) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+// staleLock is the age after which a lock file left behind by a crashed
+// process is ignored.
+const staleLock = 30 * time.Second
+
+// Update holds a lock file next to the key while it reads and writes it.
+func (s *File) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	unlock, err := s.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	old, err := s.Get(ctx, key)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	value, err := fn(old)
+	if err != nil || value == nil {
+		return err
+	}
+	return s.Set(ctx, key, value)
+}
+
+func (s *File) lock(ctx context.Context, key string) (func(), error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := s.path(key) + ".lock"
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(name)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Memory keeps values in this process only, so they are lost on restart and
+// not shared between function instances.
+type Memory struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+// NewMemory returns an empty memory store.
+func NewMemory() *Memory {
+	return &Memory{values: map[string][]byte{}}
+}
+
+func (s *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+func (s *Memory) Set(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (s *Memory) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.values[key]
+	if ok {
+		old = append([]byte(nil), old...)
+	}
+	value, err := fn(old)
+	if err != nil || value == nil {
+		return err
+	}
+	s.values[key] = append([]byte(nil), value...)
+	return nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// increment bumps a decimal counter by one.
+func increment(old []byte) ([]byte, error) {
+	n := 0
+	if old != nil {
+		var err error
+		if n, err = strconv.Atoi(string(old)); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(strconv.Itoa(n + 1)), nil
+}
+
+// testUpdate runs concurrent increments and checks none was lost.
+func testUpdate(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Update(ctx, "counter", increment); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	b, err := s.Get(ctx, "counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "20" {
+		t.Errorf("counter: got %s, want 20", b)
+	}
+
+	unchanged := func([]byte) ([]byte, error) { return nil, nil }
+	if err := s.Update(ctx, "counter", unchanged); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := s.Get(ctx, "counter"); string(b) != "20" {
+		t.Errorf("after a nil update: got %s, want 20", b)
+	}
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	testUpdate(t, NewMemory())
+}
+
+func TestFileUpdate(t *testing.T) {
+	testUpdate(t, &File{Dir: t.TempDir()})
+}
+
+func TestRedisUpdate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	s := &Redis{Client: client}
+	testUpdate(t, s)
+
+	if _, err := s.Get(context.Background(), "missing"); err != ErrNotFound {
+		t.Errorf("Get missing: got %v, want ErrNotFound", err)
+	}
+}
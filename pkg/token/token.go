@@ -0,0 +1,186 @@
+// Package token issues and verifies per-user playlist tokens.
+//
+// IPTV apps cannot send headers, so tokens travel in the URL, either as
+// ?token= or as a /t/{token}/... path rewritten by vercel.json. A token is
+// base64url(JSON claims) "." base64url(HMAC-SHA256(claims)), keyed with
+// PLAYLIST_SECRET. Revocations are kept in the store. While
+// PLAYLIST_SECRET is unset the playlists stay public.
+package token
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/store"
+)
+
+var (
+	// ErrMissing is returned when a request has no token.
+	ErrMissing = errors.New("token: missing")
+	// ErrInvalid is returned for malformed or forged tokens.
+	ErrInvalid = errors.New("token: invalid")
+	// ErrExpired is returned for tokens past their expiry.
+	ErrExpired = errors.New("token: expired")
+	// ErrRevoked is returned for revoked tokens.
+	ErrRevoked = errors.New("token: revoked")
+)
+
+const revokedPrefix = "token:revoked:"
+
+// Claims is the signed content of a token.
+type Claims struct {
+	ID      string `json:"id"`
+	Subject string `json:"sub"`
+	// Profile names the policy profile the holder gets.
+	Profile   string `json:"profile,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// Enabled reports whether PLAYLIST_SECRET is set.
+func Enabled() bool {
+	return os.Getenv("PLAYLIST_SECRET") != ""
+}
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("PLAYLIST_SECRET")))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Issue signs a new token for subject. A zero ttl never expires.
+func Issue(subject, profile string, ttl time.Duration) (string, Claims, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", Claims{}, err
+	}
+	now := time.Now()
+	c := Claims{ID: hex.EncodeToString(id), Subject: subject, Profile: profile, IssuedAt: now.Unix()}
+	if ttl > 0 {
+		c.ExpiresAt = now.Add(ttl).Unix()
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + sign(payload), c, nil
+}
+
+// Parse checks the signature and expiry of tok. It does not consult the
+// revocation list; use Verify for that.
+func Parse(tok string) (Claims, error) {
+	var c Claims
+	payload, sig, ok := strings.Cut(tok, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(sign(payload))) {
+		return c, ErrInvalid
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return c, ErrInvalid
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, ErrInvalid
+	}
+	if c.ExpiresAt != 0 && time.Now().Unix() >= c.ExpiresAt {
+		return c, ErrExpired
+	}
+	return c, nil
+}
+
+// Verify parses tok and checks that it has not been revoked.
+func Verify(ctx context.Context, s store.Store, tok string) (Claims, error) {
+	c, err := Parse(tok)
+	if err != nil {
+		return c, err
+	}
+	if _, err := s.Get(ctx, revokedPrefix+c.ID); err == nil {
+		return c, ErrRevoked
+	} else if err != store.ErrNotFound {
+		return c, err
+	}
+	return c, nil
+}
+
+// Revoke adds the token id to the revocation list.
+func Revoke(ctx context.Context, s store.Store, id string) error {
+	return s.Set(ctx, revokedPrefix+id, []byte(time.Now().UTC().Format(time.RFC3339)))
+}
+
+// Revoked reports whether the token id has been revoked.
+func Revoked(ctx context.Context, s store.Store, id string) (bool, error) {
+	_, err := s.Get(ctx, revokedPrefix+id)
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Authorize checks the request's token when tokens are enabled and returns
+// the caller's policy profile and the token, which is "" for public
+// playlists. With tokens enabled the profile comes only from the token.
+func Authorize(r *http.Request) (policy.Profile, string, error) {
+	if !Enabled() {
+		p, err := policy.FromRequest(r)
+		return p, "", err
+	}
+	tok := FromRequest(r)
+	if tok == "" {
+		return policy.Profile{}, "", ErrMissing
+	}
+	c, err := Verify(r.Context(), store.FromEnv(), tok)
+	if err != nil {
+		return policy.Profile{}, "", err
+	}
+	p, err := policy.ForRequest(r, c.Profile)
+	return p, tok, err
+}
+
+// FromRequest returns the request's token: the ?token= parameter, which
+// vercel.json fills in from /t/{token}/... paths, or else the {token} of
+// such a path, for requests that were not rewritten.
+func FromRequest(r *http.Request) string {
+	if tok := r.URL.Query().Get("token"); tok != "" {
+		return tok
+	}
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/t/"); ok {
+		if tok, _, ok := strings.Cut(rest, "/"); ok {
+			return tok
+		}
+	}
+	return ""
+}
+
+// Status maps an Authorize error to an HTTP status.
+func Status(err error) int {
+	switch err {
+	case ErrMissing, ErrInvalid, ErrExpired, ErrRevoked:
+		return http.StatusUnauthorized
+	case policy.ErrUnknownProfile:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// StreamURL returns the signed redirect URL for a channel's stream.
+func StreamURL(baseURL, channelID, tok string) string {
+	return baseURL + "/api/stream/" + url.PathEscape(channelID) + "?token=" + url.QueryEscape(tok)
+}
+
+// Key returns a short stable identifier for tok, for use in cache keys.
+func Key(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,190 @@
+package token
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/store"
+)
+
+func noStore(t *testing.T) {
+	t.Helper()
+	t.Setenv("REDIS_URL", "")
+	t.Setenv("STORE_DIR", "")
+	t.Setenv("POLICY_PROFILES", `{"family": {"tier": "subscriber"}}`)
+	t.Setenv("GEO_RESTRICT", "")
+}
+
+// encode signs c with the current PLAYLIST_SECRET.
+func encode(t *testing.T, c Claims) string {
+	t.Helper()
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + sign(payload)
+}
+
+func TestParse(t *testing.T) {
+	t.Setenv("PLAYLIST_SECRET", "secret")
+	valid, claims, err := Issue("alice", "family", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, _ := strings.Cut(valid, ".")
+	// Claims for another subject under the original signature.
+	forgedClaims, _ := json.Marshal(Claims{ID: claims.ID, Subject: "mallory", Profile: "family", IssuedAt: claims.IssuedAt})
+	tampered := base64.RawURLEncoding.EncodeToString(forgedClaims) + "." + sig
+
+	now := time.Now().Unix()
+	expired := encode(t, Claims{ID: "old", Subject: "alice", IssuedAt: now - 7200, ExpiresAt: now - 3600})
+	forever := encode(t, Claims{ID: "forever", Subject: "alice", IssuedAt: now})
+
+	tests := []struct {
+		name    string
+		tok     string
+		secret  string
+		want    error
+		subject string
+	}{
+		{name: "valid", tok: valid, subject: "alice"},
+		{name: "no expiry", tok: forever, subject: "alice"},
+		{name: "expired", tok: expired, want: ErrExpired},
+		{name: "tampered claims", tok: tampered, want: ErrInvalid},
+		{name: "forged signature", tok: payload + "." + base64.RawURLEncoding.EncodeToString([]byte("not the mac")), want: ErrInvalid},
+		{name: "signature missing", tok: payload, want: ErrInvalid},
+		{name: "wrong secret", tok: valid, secret: "other", want: ErrInvalid},
+		{name: "garbage", tok: "a.b", want: ErrInvalid},
+		{name: "empty", tok: "", want: ErrInvalid},
+	}
+	for _, tt := range tests {
+		secret := tt.secret
+		if secret == "" {
+			secret = "secret"
+		}
+		t.Setenv("PLAYLIST_SECRET", secret)
+		c, err := Parse(tt.tok)
+		if err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil && c.Subject != tt.subject {
+			t.Errorf("%s: got subject %q, want %q", tt.name, c.Subject, tt.subject)
+		}
+		if err != nil && Status(err) != http.StatusUnauthorized {
+			t.Errorf("%s: got status %d, want 401", tt.name, Status(err))
+		}
+	}
+}
+
+// brokenStore fails every read.
+type brokenStore struct{ store.Store }
+
+func (brokenStore) Get(context.Context, string) ([]byte, error) {
+	return nil, errors.New("store unreachable")
+}
+
+func TestVerifyRevoked(t *testing.T) {
+	t.Setenv("PLAYLIST_SECRET", "secret")
+	ctx := context.Background()
+	s := store.NewMemory()
+	tok, claims, err := Issue("alice", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(ctx, s, tok); err != nil {
+		t.Fatalf("before revoking: got %v", err)
+	}
+	if err := Revoke(ctx, s, claims.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(ctx, s, tok); err != ErrRevoked {
+		t.Errorf("after revoking: got %v, want ErrRevoked", err)
+	}
+	if revoked, err := Revoked(ctx, s, claims.ID); !revoked || err != nil {
+		t.Errorf("Revoked: got %t, %v", revoked, err)
+	}
+
+	// A revocation list that cannot be read fails closed.
+	_, err = Verify(ctx, brokenStore{s}, tok)
+	if err == nil || Status(err) != http.StatusInternalServerError {
+		t.Errorf("unreadable store: got %v, want a 500 error", err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	noStore(t)
+
+	t.Setenv("PLAYLIST_SECRET", "")
+	p, tok, err := Authorize(httptest.NewRequest(http.MethodGet, "/api/m3u?token=anything", nil))
+	if err != nil || tok != "" || p.Name == "" {
+		t.Errorf("without PLAYLIST_SECRET: got profile %+v, token %q, %v; want the default profile", p, tok, err)
+	}
+
+	t.Setenv("PLAYLIST_SECRET", "secret")
+	valid, _, err := Issue("alice", "family", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, _, err := Issue("bob", "nope", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		target  string
+		want    error
+		profile string
+	}{
+		{name: "query token", target: "/api/m3u?token=" + valid, profile: "family"},
+		{name: "path token", target: "/t/" + valid + "/M3U", profile: "family"},
+		{name: "token ignores ?profile=", target: "/api/m3u?profile=free&token=" + valid, profile: "family"},
+		{name: "no token", target: "/api/m3u", want: ErrMissing},
+		{name: "path without a document", target: "/t/" + valid, want: ErrMissing},
+		{name: "forged token", target: "/api/m3u?token=" + valid + "x", want: ErrInvalid},
+		{name: "unknown profile", target: "/api/m3u?token=" + unknown, want: policy.ErrUnknownProfile},
+	}
+	for _, tt := range tests {
+		p, tok, err := Authorize(httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil && (p.Name != tt.profile || tok != valid) {
+			t.Errorf("%s: got profile %q and token %q", tt.name, p.Name, tok)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	for target, want := range map[string]string{
+		"/api/m3u?token=abc.def":         "abc.def",
+		"/t/abc.def/M3U":                 "abc.def",
+		"/t/abc.def/xmltv.xml?token=q.r": "q.r",
+		"/api/m3u":                       "",
+		"/t/":                            "",
+		"/tokens/abc.def/M3U":            "",
+	} {
+		if got := FromRequest(httptest.NewRequest(http.MethodGet, target, nil)); got != want {
+			t.Errorf("%s: got %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestStreamURL(t *testing.T) {
+	got := StreamURL("https://iptv.example.com", "news/tvj", "abc.d+f")
+	want := "https://iptv.example.com/api/stream/news%2Ftvj?token=abc.d%2Bf"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+// Package vod separates on-demand items from live channels and organizes
+// them into a catalogue of categories and series.
+package vod
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"template-go-vercel/pkg/m3u"
+	"template-go-vercel/pkg/source"
+)
+
+// Uncategorized names the category of items without one.
+const Uncategorized = "Uncategorized"
+
+// Split returns the live channels and the on-demand items of channels.
+func Split(channels []source.Channel) (live, vod []source.Channel) {
+	for _, ch := range channels {
+		if ch.IsLive() {
+			live = append(live, ch)
+		} else {
+			vod = append(vod, ch)
+		}
+	}
+	return live, vod
+}
+
+// Category is a VOD category with the number of series in it.
+type Category struct {
+	Name   string `json:"name"`
+	Series int    `json:"series"`
+}
+
+// Episode is a single on-demand item.
+type Episode struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	AiredAt    time.Time `json:"aired_at,omitempty"`
+	URL        string    `json:"url"`
+	Poster     string    `json:"poster,omitempty"`
+	PosterWide string    `json:"poster_wide,omitempty"`
+}
+
+// Series groups episodes sharing a SeriesID, oldest first. Items without a
+// SeriesID form a series of their own.
+type Series struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	Category   string    `json:"category"`
+	Poster     string    `json:"poster,omitempty"`
+	PosterWide string    `json:"poster_wide,omitempty"`
+	Episodes   []Episode `json:"episodes"`
+}
+
+// Catalogue is the full on-demand library.
+type Catalogue struct {
+	Categories []Category `json:"categories"`
+	Series     []Series   `json:"series"`
+}
+
+// Build organizes on-demand items into a catalogue.
+func Build(items []source.Channel) Catalogue {
+	index := map[string]int{}
+	var series []Series
+	for _, item := range items {
+		id := item.SeriesID
+		if id == "" {
+			id = item.ID
+		}
+		i, ok := index[id]
+		if !ok {
+			category := item.Category
+			if category == "" {
+				category = Uncategorized
+			}
+			i = len(series)
+			index[id] = i
+			series = append(series, Series{ID: id, Category: category})
+		}
+		s := &series[i]
+		s.Episodes = append(s.Episodes, Episode{
+			ID:         item.ID,
+			Title:      item.Name,
+			AiredAt:    item.AiredAt,
+			URL:        item.StreamURL,
+			Poster:     item.Poster,
+			PosterWide: item.PosterWide,
+		})
+		if s.Poster == "" {
+			s.Poster = item.Poster
+		}
+		if s.PosterWide == "" {
+			s.PosterWide = item.PosterWide
+		}
+	}
+
+	counts := map[string]int{}
+	for i := range series {
+		s := &series[i]
+		sort.SliceStable(s.Episodes, func(a, b int) bool {
+			return s.Episodes[a].AiredAt.Before(s.Episodes[b].AiredAt)
+		})
+		s.Title = seriesTitle(s.Episodes)
+		counts[s.Category]++
+	}
+	sort.SliceStable(series, func(a, b int) bool { return series[a].Title < series[b].Title })
+
+	c := Catalogue{Categories: []Category{}, Series: series}
+	for name, n := range counts {
+		c.Categories = append(c.Categories, Category{Name: name, Series: n})
+	}
+	sort.Slice(c.Categories, func(a, b int) bool { return c.Categories[a].Name < c.Categories[b].Name })
+	if c.Series == nil {
+		c.Series = []Series{}
+	}
+	return c
+}
+
+// seriesTitle derives a series name from its episode titles, which the feed
+// does not provide: the common prefix of the titles up to a separator, or
+// the first title when they have nothing in common.
+func seriesTitle(episodes []Episode) string {
+	if len(episodes) == 0 {
+		return ""
+	}
+	prefix := episodes[0].Title
+	for _, e := range episodes[1:] {
+		for !strings.HasPrefix(e.Title, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	if len(episodes) > 1 {
+		// Cut back to the last separator so partial words are dropped.
+		cut := strings.LastIndex(prefix, " ")
+		for _, sep := range []string{" - ", ": ", " | "} {
+			if i := strings.LastIndex(prefix, sep); i > 0 {
+				cut = i
+				break
+			}
+		}
+		if cut > 0 {
+			prefix = prefix[:cut]
+		}
+	}
+	prefix = strings.TrimRight(prefix, " -:|,")
+	if len(episodes) == 1 || prefix == "" {
+		return episodes[0].Title
+	}
+	return prefix
+}
+
+// InCategory returns the series in the named category.
+func (c Catalogue) InCategory(name string) []Series {
+	out := []Series{}
+	for _, s := range c.Series {
+		if strings.EqualFold(s.Category, name) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Find returns the series with the given id.
+func (c Catalogue) Find(id string) (Series, bool) {
+	for _, s := range c.Series {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Series{}, false
+}
+
+// Playlist returns the catalogue as playlist entries, grouped by series and
+// ordered by air date within each series.
+func (c Catalogue) Playlist() []*m3u.EXTINF {
+	var list []*m3u.EXTINF
+	for _, s := range c.Series {
+		for _, e := range s.Episodes {
+			logo := e.Poster
+			if logo == "" {
+				logo = s.Poster
+			}
+			list = append(list, &m3u.EXTINF{
+				Id:      e.ID,
+				Name:    e.Title,
+				NewName: e.Title,
+				Title:   e.Title,
+				Logo:    logo,
+				Url:     e.URL,
+				Group:   s.Title,
+				Number:  len(list) + 1,
+			})
+		}
+	}
+	return list
+}
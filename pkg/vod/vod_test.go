@@ -0,0 +1,114 @@
+package vod
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"template-go-vercel/pkg/source"
+)
+
+func items(t *testing.T) []source.Channel {
+	t.Helper()
+	b, err := os.ReadFile("testdata/items.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var channels []source.Channel
+	if err := json.Unmarshal(b, &channels); err != nil {
+		t.Fatal(err)
+	}
+	return channels
+}
+
+func TestSplit(t *testing.T) {
+	t.Setenv("LIVE_MEDIA_TYPES", "")
+	live, vod := Split(items(t))
+	if len(live) != 1 || live[0].ID != "tvj" {
+		t.Errorf("live: got %+v", live)
+	}
+	if len(vod) != 5 {
+		t.Errorf("got %d on-demand items, want 5", len(vod))
+	}
+}
+
+func TestBuild(t *testing.T) {
+	t.Setenv("LIVE_MEDIA_TYPES", "")
+	_, items := Split(items(t))
+	c := Build(items)
+
+	var titles []string
+	for _, s := range c.Series {
+		titles = append(titles, s.Title)
+	}
+	want := []string{"Island Stories", "Prime Time News", "Talk Up"}
+	if len(titles) != len(want) {
+		t.Fatalf("got series %q, want %q", titles, want)
+	}
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Errorf("series %d: got %q, want %q", i, titles[i], want[i])
+		}
+	}
+
+	news, ok := c.Find("news")
+	if !ok || len(news.Episodes) != 2 || news.Episodes[0].ID != "news-1017" || news.Poster != "https://img.example.com/news-1018.jpg" {
+		t.Errorf("news: got %+v, want episodes oldest first and the series poster", news)
+	}
+	doc, ok := c.Find("doc")
+	if !ok || doc.Category != Uncategorized || doc.PosterWide == "" {
+		t.Errorf("item without series: got %+v", doc)
+	}
+
+	cats := map[string]int{}
+	for _, cat := range c.Categories {
+		cats[cat.Name] = cat.Series
+	}
+	if len(cats) != 3 || cats["News"] != 1 || cats["Talk"] != 1 || cats[Uncategorized] != 1 {
+		t.Errorf("categories: got %+v", c.Categories)
+	}
+	if talk := c.InCategory("talk"); len(talk) != 1 || talk[0].ID != "talk" {
+		t.Errorf("InCategory talk: got %+v", talk)
+	}
+
+	list := c.Playlist()
+	if len(list) != 5 || list[1].Group != "Prime Time News" || list[1].Id != "news-1017" || list[1].Number != 2 {
+		t.Errorf("playlist: got %+v", list[1])
+	}
+}
+
+func TestBuildEmpty(t *testing.T) {
+	c := Build(nil)
+	if c.Series == nil || c.Categories == nil {
+		t.Errorf("got %+v, want empty lists rather than null", c)
+	}
+}
+
+func TestSeriesTitle(t *testing.T) {
+	ep := func(titles ...string) []Episode {
+		var out []Episode
+		for _, title := range titles {
+			out = append(out, Episode{Title: title})
+		}
+		return out
+	}
+	for _, tt := range []struct {
+		titles []string
+		want   string
+	}{
+		{[]string{"Prime Time News - 17 October", "Prime Time News - 18 October"}, "Prime Time News"},
+		{[]string{"Talk Up: Elections", "Talk Up: Economy"}, "Talk Up"},
+		{[]string{"Sports | Football", "Sports | Cricket"}, "Sports"},
+		// Without a separator the prefix is cut back to a whole word.
+		{[]string{"Morning Show Monday", "Morning Show Tuesday"}, "Morning Show"},
+		{[]string{"Weekend Special"}, "Weekend Special"},
+		{[]string{"Island Stories", "Cooking Time"}, "Island Stories"},
+		// Multibyte titles are cut on rune boundaries.
+		{[]string{"Café Olé 1", "Café Olé 2"}, "Café Olé"},
+		{nil, ""},
+	} {
+		if got := seriesTitle(ep(tt.titles...)); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.titles, got, tt.want)
+		}
+	}
+}
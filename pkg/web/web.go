@@ -0,0 +1,14 @@
+// Package web holds small helpers shared by the HTTP handlers.
+package web
+
+import "net/http"
+
+// BaseURL returns the scheme and host the client used to reach the
+// deployment, honouring X-Forwarded-Proto set by the Vercel edge.
+func BaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
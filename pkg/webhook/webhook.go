@@ -0,0 +1,190 @@
+// Package webhook delivers signed JSON event notifications.
+//
+// Every delivery is a POST with the event as JSON and these headers:
+//
+//	X-Webhook-Event      the event name
+//	X-Webhook-Timestamp  Unix seconds when the request was signed
+//	X-Webhook-Signature  sha256=hex(HMAC-SHA256(WEBHOOK_SECRET, timestamp "." body))
+//
+// Receivers recompute the signature with Verify and should reject old
+// timestamps to prevent replays. Nothing is sent without WEBHOOK_SECRET.
+//
+// Webhook URLs supplied by users are sent with NewPublic, which refuses to
+// connect to loopback, private, link-local and other non-public addresses,
+// checked on the resolved address of every connection.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrNoSecret is returned by Send when no signing secret is set.
+	ErrNoSecret = errors.New("webhook: WEBHOOK_SECRET is not set")
+	// ErrPrivateAddress is returned for webhooks to non-public addresses.
+	ErrPrivateAddress = errors.New("webhook: refusing to connect to a non-public address")
+)
+
+// Sender posts events to webhook URLs.
+type Sender struct {
+	Client *http.Client
+	// Secret signs the requests; Send refuses to deliver without it.
+	Secret string
+	// Now returns the current time; tests may replace it.
+	Now func() time.Time
+}
+
+// timeout bounds each delivery.
+const timeout = 5 * time.Second
+
+// Enabled reports whether WEBHOOK_SECRET is set, without which nothing is
+// delivered.
+func Enabled() bool {
+	return os.Getenv("WEBHOOK_SECRET") != ""
+}
+
+// New returns a Sender signing with WEBHOOK_SECRET, for webhook URLs
+// configured by the operator.
+func New() *Sender {
+	return &Sender{
+		Client: &http.Client{Timeout: timeout},
+		Secret: os.Getenv("WEBHOOK_SECRET"),
+	}
+}
+
+// NewPublic returns a Sender signing with WEBHOOK_SECRET that only connects
+// to public addresses, for webhook URLs supplied by users. Proxies are not
+// used, so the check applies to the receiver itself, including after
+// redirects and DNS changes.
+func NewPublic() *Sender {
+	dialer := &net.Dialer{Timeout: timeout, Control: publicOnly}
+	return &Sender{
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+		Secret: os.Getenv("WEBHOOK_SECRET"),
+	}
+}
+
+// publicOnly is a net.Dialer Control function refusing non-public
+// addresses, called with the resolved address of each connection.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !Public(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Public reports whether ip is a globally routable unicast address.
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// CheckURL returns an error unless raw is an http or https URL whose host
+// is not obviously internal: localhost or a non-public IP literal. Host
+// names are checked again when NewPublic connects.
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("webhook_url must be an http or https URL")
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !Public(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// StatusError is returned when a receiver answers with a non-2xx status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook %s answered %d", e.URL, e.StatusCode)
+}
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature matches body and timestamp.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Send posts payload as JSON to url as the named event. It returns
+// ErrNoSecret without sending when the Sender has no Secret.
+func (s *Sender) Send(ctx context.Context, url, event string, payload interface{}) error {
+	if s.Secret == "" {
+		return ErrNoSecret
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "template-go-vercel-webhook")
+	req.Header.Set("X-Webhook-Event", event)
+	ts := now.Unix()
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(s.Secret, ts, body))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"event":"test"}`)
+	sig := Sign("secret", 1700000000, body)
+	if !Verify("secret", 1700000000, body, sig) {
+		t.Error("Verify rejected its own signature")
+	}
+	for name, ok := range map[string]bool{
+		"other secret":    Verify("other", 1700000000, body, sig),
+		"other timestamp": Verify("secret", 1700000001, body, sig),
+		"other body":      Verify("secret", 1700000000, []byte(`{}`), sig),
+	} {
+		if ok {
+			t.Errorf("Verify accepted the signature with an %s", name)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s := &Sender{Client: srv.Client(), Secret: "secret", Now: func() time.Time { return now }}
+	if err := s.Send(context.Background(), srv.URL, "programme.reminder", map[string]string{"title": "News"}); err != nil {
+		t.Fatal(err)
+	}
+	ts, _ := strconv.ParseInt(got.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	if ts != now.Unix() || got.Header.Get("X-Webhook-Event") != "programme.reminder" {
+		t.Errorf("headers: got %v", got.Header)
+	}
+	if !Verify("secret", ts, gotBody, got.Header.Get("X-Webhook-Signature")) {
+		t.Error("receiver could not verify the signature")
+	}
+
+	got = nil
+	unsigned := &Sender{Client: srv.Client()}
+	if err := unsigned.Send(context.Background(), srv.URL, "test", nil); err != ErrNoSecret {
+		t.Errorf("without a secret: got %v, want ErrNoSecret", err)
+	}
+	if got != nil {
+		t.Error("a webhook was sent without a secret")
+	}
+}
+
+func TestSendStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	s := &Sender{Client: srv.Client(), Secret: "secret"}
+	var se *StatusError
+	if err := s.Send(context.Background(), srv.URL, "test", nil); !errors.As(err, &se) || se.StatusCode != 500 {
+		t.Errorf("got %v, want a 500 StatusError", err)
+	}
+}
+
+func TestNewPublicRefusesLoopback(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "secret")
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = true }))
+	defer srv.Close()
+
+	err := NewPublic().Send(context.Background(), srv.URL, "test", nil)
+	if !errors.Is(err, ErrPrivateAddress) || hit {
+		t.Errorf("got %v (delivered: %t), want ErrPrivateAddress", err, hit)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	for ip, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	} {
+		if got := Public(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("Public(%s): got %t, want %t", ip, got, want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://hooks.example.com/x": true,
+		"http://93.184.216.34/x":      true,
+		"ftp://hooks.example.com/x":   false,
+		"https://":                    false,
+		"http://localhost:8080/x":     false,
+		"http://api.localhost/x":      false,
+		"http://127.0.0.1/x":          false,
+		"http://169.254.169.254/":     false,
+		"http://[::1]:9000/x":         false,
+		"http://[::ffff:10.0.0.1]/x":  false,
+	} {
+		if err := CheckURL(raw); (err == nil) != ok {
+			t.Errorf("CheckURL(%q): got %v, want ok=%t", raw, err, ok)
+		}
+	}
+}
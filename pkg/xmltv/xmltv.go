@@ -0,0 +1,194 @@
+// Package xmltv generates XMLTV guides from the normalized channel model.
+package xmltv
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"template-go-vercel/pkg/source"
+)
+
+// TimeFormat is the XMLTV date format used for programme start and stop.
+const TimeFormat = "20060102150405 -0700"
+
+// ContentType is the media type guides are served with.
+const ContentType = "application/xml"
+
+// Tv struct represents the root <tv> element in XMLTV.
+type Tv struct {
+	XMLName           xml.Name       `xml:"tv"`
+	Date              string         `xml:"date,attr"`
+	GeneratorInfoName string         `xml:"generator-info-name,attr"`
+	SourceInfoName    string         `xml:"source-info-name,attr"`
+	Channels          []XmltvChannel `xml:"channel"`
+	Programmes        []Programme    `xml:"programme"`
+}
+
+// XmltvChannel represents a <channel> element in XMLTV.
+type XmltvChannel struct {
+	XMLName     xml.Name      `xml:"channel"`
+	ID          string        `xml:"id,attr"`
+	DisplayName []DisplayName `xml:"display-name"`
+	Icon        *Icon         `xml:"icon,omitempty"` // Add icon for channel logo
+	URL         string        `xml:"url"`
+}
+
+// Icon represents an <icon> element for channel logos.
+type Icon struct {
+	XMLName xml.Name `xml:"icon"`
+	Src     string   `xml:"src,attr"`
+}
+
+// DisplayName represents a <display-name> element in XMLTV.
+type DisplayName struct {
+	XMLName xml.Name `xml:"display-name"`
+	Lang    string   `xml:"lang,attr"`
+	Text    string   `xml:",chardata"`
+}
+
+// Programme represents a <programme> element in XMLTV.
+type Programme struct {
+	XMLName  xml.Name   `xml:"programme"`
+	Start    string     `xml:"start,attr"`
+	Stop     string     `xml:"stop,attr"`
+	Channel  string     `xml:"channel,attr"`
+	Title    []Title    `xml:"title"`
+	Desc     []Desc     `xml:"desc"`
+	Category []Category `xml:"category,omitempty"` // Add category
+	Rating   *Rating    `xml:"rating,omitempty"`   // Add rating
+}
+
+// Title represents a <title> element in XMLTV.
+type Title struct {
+	XMLName xml.Name `xml:"title"`
+	Lang    string   `xml:"lang,attr"`
+	Text    string   `xml:",chardata"`
+}
+
+// Desc represents a <desc> element in XMLTV.
+type Desc struct {
+	XMLName xml.Name `xml:"desc"`
+	Lang    string   `xml:"lang,attr"`
+	Text    string   `xml:",chardata"`
+}
+
+// Category represents a <category> element in XMLTV.
+type Category struct {
+	XMLName xml.Name `xml:"category"`
+	Lang    string   `xml:"lang,attr"`
+	Text    string   `xml:",chardata"`
+}
+
+// Rating represents a <rating> element in XMLTV.
+type Rating struct {
+	XMLName xml.Name `xml:"rating"`
+	System  string   `xml:"system,attr,omitempty"`
+	Value   string   `xml:"value"`
+}
+
+// Generate builds an XMLTV document from channels. Channels are listed
+// under their guide id, once for all the variants sharing it. Channels
+// without any programmes get three hourly placeholder programmes.
+func Generate(channels []source.Channel) ([]byte, error) {
+	tv := Tv{
+		Date:              time.Now().Format("20060102"),
+		GeneratorInfoName: "MyGoEPGGenerator",
+		SourceInfoName:    "EPG Data from Go Application",
+	}
+
+	listed := map[string]bool{}
+	for _, ch := range channels {
+		id := ch.EPGID()
+		if listed[id] {
+			continue
+		}
+		listed[id] = true
+		url := ch.GuideURL
+		if url == "" {
+			url = ch.StreamURL
+		}
+		xmltvChannel := XmltvChannel{
+			ID: id,
+			DisplayName: []DisplayName{
+				{Lang: "en", Text: ch.Name},
+			},
+			URL: url,
+		}
+		// Add channel logo if available
+		if ch.Logo != "" {
+			xmltvChannel.Icon = &Icon{Src: ch.Logo}
+		}
+		tv.Channels = append(tv.Channels, xmltvChannel)
+
+		if len(ch.Programmes) > 0 {
+			for _, event := range ch.Programmes {
+				startFormatted := event.Start.Format(TimeFormat)
+				stopFormatted := event.End.Format(TimeFormat)
+
+				desc := event.Description
+				if desc == "" {
+					desc = fmt.Sprintf("Duration: %d minutes. Rating: %s.", event.Duration, event.Rating)
+				}
+				programme := Programme{
+					Start:   startFormatted,
+					Stop:    stopFormatted,
+					Channel: id,
+					Title: []Title{
+						{Lang: "en", Text: event.Title},
+					},
+					Desc: []Desc{
+						{Lang: "en", Text: desc},
+					},
+				}
+
+				// Add category if available
+				if ch.Category != "" {
+					programme.Category = []Category{{Lang: "en", Text: ch.Category}}
+				}
+
+				// Add rating if available
+				if event.Rating != "" {
+					programme.Rating = &Rating{System: "MPAA", Value: event.Rating}
+				}
+
+				tv.Programmes = append(tv.Programmes, programme)
+			}
+		} else {
+			// If no EPG events are present, generate some dummy programs
+			now := time.Now().UTC()
+			programDuration := time.Hour
+
+			for p_num := 0; p_num < 3; p_num++ {
+				startTime := now.Add(time.Duration(p_num) * programDuration)
+				stopTime := startTime.Add(programDuration)
+
+				programme := Programme{
+					Start:   startTime.Format(TimeFormat),
+					Stop:    stopTime.Format(TimeFormat),
+					Channel: id,
+					Title: []Title{
+						{Lang: "en", Text: fmt.Sprintf("Dummy Show %d on %s", p_num+1, ch.Name)},
+					},
+					Desc: []Desc{
+						{Lang: "en", Text: fmt.Sprintf("This is a placeholder description for Dummy Show %d on %s.", p_num+1, ch.Name)},
+					},
+				}
+				tv.Programmes = append(tv.Programmes, programme)
+			}
+		}
+	}
+
+	xmlBytes, err := xml.MarshalIndent(tv, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling XML: %w", err)
+	}
+
+	xmlDeclaration := []byte(xml.Header)
+	doctypeDeclaration := []byte(`<!DOCTYPE tv SYSTEM "xmltv.dtd">` + "\n")
+
+	finalXML := append(xmlDeclaration, doctypeDeclaration...)
+	finalXML = append(finalXML, xmlBytes...)
+
+	return finalXML, nil
+}
@@ -0,0 +1,62 @@
+package xmltv
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"template-go-vercel/pkg/source"
+)
+
+func TestGenerateChannelURL(t *testing.T) {
+	channels := []source.Channel{
+		{ID: "hls", Name: "HLS", StreamURL: "https://cdn.example.com/android.m3u8", GuideURL: "https://cdn.example.com/hls.m3u8"},
+		{ID: "plain", Name: "Plain", StreamURL: "https://cdn.example.com/plain.m3u8"},
+	}
+	b, err := Generate(channels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tv Tv
+	if err := xml.Unmarshal(b, &tv); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"hls":   "https://cdn.example.com/hls.m3u8",
+		"plain": "https://cdn.example.com/plain.m3u8",
+	}
+	if len(tv.Channels) != len(want) {
+		t.Fatalf("got %d channels, want %d", len(tv.Channels), len(want))
+	}
+	for _, ch := range tv.Channels {
+		if ch.URL != want[ch.ID] {
+			t.Errorf("channel %s: url = %q, want %q", ch.ID, ch.URL, want[ch.ID])
+		}
+	}
+}
+
+func TestGenerateSharedGuideID(t *testing.T) {
+	channels := []source.Channel{
+		{ID: "101", GuideID: "tvj", Name: "TVJ HD"},
+		{ID: "103", GuideID: "tvj", Name: "TVJ SD"},
+		{ID: "102", Name: "CVM"},
+	}
+	b, err := Generate(channels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tv Tv
+	if err := xml.Unmarshal(b, &tv); err != nil {
+		t.Fatal(err)
+	}
+	if len(tv.Channels) != 2 || tv.Channels[0].ID != "tvj" || tv.Channels[1].ID != "102" {
+		t.Fatalf("got channels %+v, want tvj once and 102", tv.Channels)
+	}
+	for _, p := range tv.Programmes {
+		if p.Channel != "tvj" && p.Channel != "102" {
+			t.Errorf("programme on %q", p.Channel)
+		}
+	}
+	if len(tv.Programmes) != 6 {
+		t.Errorf("got %d programmes, want the placeholders of 2 channels", len(tv.Programmes))
+	}
+}
@@ -0,0 +1,273 @@
+// Package xtream builds Xtream Codes player_api.php compatible responses
+// from the normalized channel model, for players that only speak that API.
+// Logins use the shared XTREAM_USERNAME and XTREAM_PASSWORD, or, once
+// PLAYLIST_SECRET makes the playlists private, per-user playlist tokens.
+package xtream
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"template-go-vercel/pkg/policy"
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/store"
+	"template-go-vercel/pkg/token"
+)
+
+// Credentials returns the facade's username and password from
+// XTREAM_USERNAME and XTREAM_PASSWORD. ok is false when either is unset.
+func Credentials() (user, pass string, ok bool) {
+	user, pass = os.Getenv("XTREAM_USERNAME"), os.Getenv("XTREAM_PASSWORD")
+	return user, pass, user != "" && pass != ""
+}
+
+// ErrLogin is returned by Authorize for a wrong username or password.
+var ErrLogin = errors.New("xtream: invalid username or password")
+
+// Authorize checks the request's username and password and returns the
+// caller's policy profile and playlist token. With PLAYLIST_SECRET set the
+// password is a playlist token and the username its subject, so each user
+// logs in with their own token; otherwise they are the shared
+// XTREAM_USERNAME and XTREAM_PASSWORD and the token is "".
+func Authorize(r *http.Request) (policy.Profile, string, error) {
+	q := r.URL.Query()
+	user, pass := q.Get("username"), q.Get("password")
+	if !token.Enabled() {
+		if !Authorized(user, pass) {
+			return policy.Profile{}, "", ErrLogin
+		}
+		p, err := policy.FromRequest(r)
+		return p, "", err
+	}
+	c, err := token.Verify(r.Context(), store.FromEnv(), pass)
+	switch err {
+	case nil:
+	case token.ErrInvalid:
+		return policy.Profile{}, "", ErrLogin
+	default:
+		return policy.Profile{}, "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(user), []byte(c.Subject)) != 1 {
+		return policy.Profile{}, "", ErrLogin
+	}
+	p, err := policy.ForRequest(r, c.Profile)
+	return p, pass, err
+}
+
+// Authorized reports whether user and pass match the configured credentials.
+func Authorized(user, pass string) bool {
+	wantUser, wantPass, ok := Credentials()
+	if !ok {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(wantUser)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(wantPass)) == 1
+	return userOK && passOK
+}
+
+// StreamID maps a channel id to the stable numeric id Xtream clients expect.
+func StreamID(channelID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(channelID))
+	return int(h.Sum32() & 0x7fffffff)
+}
+
+// CategoryID maps a group name to a stable category id.
+func CategoryID(group string) string {
+	return strconv.Itoa(StreamID("group:" + group))
+}
+
+// Find returns the channel with the given numeric stream id.
+func Find(channels []source.Channel, streamID int) (source.Channel, bool) {
+	for _, ch := range channels {
+		if StreamID(ch.ID) == streamID {
+			return ch, true
+		}
+	}
+	return source.Channel{}, false
+}
+
+// UserInfo is the user_info object of the login response.
+type UserInfo struct {
+	Username             string   `json:"username"`
+	Password             string   `json:"password"`
+	Message              string   `json:"message"`
+	Auth                 int      `json:"auth"`
+	Status               string   `json:"status"`
+	ExpDate              *string  `json:"exp_date"`
+	IsTrial              string   `json:"is_trial"`
+	ActiveCons           string   `json:"active_cons"`
+	CreatedAt            string   `json:"created_at"`
+	MaxConnections       string   `json:"max_connections"`
+	AllowedOutputFormats []string `json:"allowed_output_formats"`
+}
+
+// ServerInfo is the server_info object of the login response.
+type ServerInfo struct {
+	URL            string `json:"url"`
+	Port           string `json:"port"`
+	HTTPSPort      string `json:"https_port"`
+	ServerProtocol string `json:"server_protocol"`
+	RTMPPort       string `json:"rtmp_port"`
+	Timezone       string `json:"timezone"`
+	TimestampNow   int64  `json:"timestamp_now"`
+	TimeNow        string `json:"time_now"`
+}
+
+// Login is the response to player_api.php without an action.
+type Login struct {
+	UserInfo   UserInfo   `json:"user_info"`
+	ServerInfo ServerInfo `json:"server_info"`
+}
+
+// NewLogin builds the login response for user on the server reached as
+// scheme://host.
+func NewLogin(user, pass, scheme, host string, now time.Time) Login {
+	port := "80"
+	if scheme == "https" {
+		port = "443"
+	}
+	return Login{
+		UserInfo: UserInfo{
+			Username:             user,
+			Password:             pass,
+			Auth:                 1,
+			Status:               "Active",
+			IsTrial:              "0",
+			ActiveCons:           "0",
+			CreatedAt:            "0",
+			MaxConnections:       "1",
+			AllowedOutputFormats: []string{"m3u8"},
+		},
+		ServerInfo: ServerInfo{
+			URL:            host,
+			Port:           port,
+			HTTPSPort:      "443",
+			ServerProtocol: scheme,
+			RTMPPort:       "0",
+			Timezone:       "UTC",
+			TimestampNow:   now.Unix(),
+			TimeNow:        now.UTC().Format("2006-01-02 15:04:05"),
+		},
+	}
+}
+
+// Category is an entry of get_live_categories.
+type Category struct {
+	CategoryID   string `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	ParentID     int    `json:"parent_id"`
+}
+
+// LiveCategories lists one category per channel group, sorted by name.
+func LiveCategories(channels []source.Channel) []Category {
+	seen := map[string]bool{}
+	cats := []Category{}
+	for _, ch := range channels {
+		g := ch.GroupName()
+		if seen[g] {
+			continue
+		}
+		seen[g] = true
+		cats = append(cats, Category{CategoryID: CategoryID(g), CategoryName: g})
+	}
+	sort.Slice(cats, func(i, j int) bool { return cats[i].CategoryName < cats[j].CategoryName })
+	return cats
+}
+
+// Stream is an entry of get_live_streams.
+type Stream struct {
+	Num          int    `json:"num"`
+	Name         string `json:"name"`
+	StreamType   string `json:"stream_type"`
+	StreamID     int    `json:"stream_id"`
+	StreamIcon   string `json:"stream_icon"`
+	EPGChannelID string `json:"epg_channel_id"`
+	Added        string `json:"added"`
+	CategoryID   string `json:"category_id"`
+	TVArchive    int    `json:"tv_archive"`
+}
+
+// LiveStreams lists the channels, limited to categoryID when it is set.
+// Xtream clients expect num to start at 1, so when the channel numbers
+// start lower (CHANNEL_NUMBER_START defaults to 0) they are all shifted up,
+// the same way for every category.
+func LiveStreams(channels []source.Channel, categoryID string) []Stream {
+	shift := 0
+	for _, ch := range channels {
+		if d := 1 - ch.Number; d > shift {
+			shift = d
+		}
+	}
+	streams := []Stream{}
+	for _, ch := range channels {
+		cat := CategoryID(ch.GroupName())
+		if categoryID != "" && cat != categoryID {
+			continue
+		}
+		streams = append(streams, Stream{
+			Num:          ch.Number + shift,
+			Name:         ch.Name,
+			StreamType:   "live",
+			StreamID:     StreamID(ch.ID),
+			StreamIcon:   ch.Logo,
+			EPGChannelID: ch.EPGID(),
+			Added:        "0",
+			CategoryID:   cat,
+		})
+	}
+	return streams
+}
+
+// Listing is an entry of get_short_epg. Title and description are base64
+// encoded, as Xtream clients expect.
+type Listing struct {
+	ID             string `json:"id"`
+	EPGID          string `json:"epg_id"`
+	Title          string `json:"title"`
+	Lang           string `json:"lang"`
+	Start          string `json:"start"`
+	End            string `json:"end"`
+	Description    string `json:"description"`
+	ChannelID      string `json:"channel_id"`
+	StartTimestamp int64  `json:"start_timestamp"`
+	StopTimestamp  int64  `json:"stop_timestamp"`
+}
+
+// ShortEPG is the response to get_short_epg.
+type ShortEPG struct {
+	Listings []Listing `json:"epg_listings"`
+}
+
+// NewShortEPG returns up to limit programmes of ch that have not ended by now.
+func NewShortEPG(ch source.Channel, limit int, now time.Time) ShortEPG {
+	epg := ShortEPG{Listings: []Listing{}}
+	for _, p := range ch.Programmes {
+		if limit > 0 && len(epg.Listings) >= limit {
+			break
+		}
+		if !p.End.After(now) {
+			continue
+		}
+		epg.Listings = append(epg.Listings, Listing{
+			ID:             strconv.FormatInt(p.Start.Unix(), 10),
+			EPGID:          ch.EPGID(),
+			Title:          base64.StdEncoding.EncodeToString([]byte(p.Title)),
+			Lang:           "en",
+			Start:          p.Start.UTC().Format("2006-01-02 15:04:05"),
+			End:            p.End.UTC().Format("2006-01-02 15:04:05"),
+			Description:    base64.StdEncoding.EncodeToString([]byte(p.Description)),
+			ChannelID:      ch.ID,
+			StartTimestamp: p.Start.Unix(),
+			StopTimestamp:  p.End.Unix(),
+		})
+	}
+	return epg
+}
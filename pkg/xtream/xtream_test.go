@@ -0,0 +1,127 @@
+package xtream
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"template-go-vercel/pkg/source"
+	"template-go-vercel/pkg/token"
+)
+
+func noStore(t *testing.T) {
+	t.Helper()
+	t.Setenv("REDIS_URL", "")
+	t.Setenv("STORE_DIR", "")
+}
+
+func login(user, pass string) string {
+	return "/player_api.php?username=" + url.QueryEscape(user) + "&password=" + url.QueryEscape(pass)
+}
+
+func TestAuthorizeSharedCredentials(t *testing.T) {
+	noStore(t)
+	t.Setenv("PLAYLIST_SECRET", "")
+	t.Setenv("XTREAM_USERNAME", "user")
+	t.Setenv("XTREAM_PASSWORD", "pass")
+
+	if _, tok, err := Authorize(httptest.NewRequest("GET", login("user", "pass"), nil)); err != nil || tok != "" {
+		t.Errorf("valid login: got token %q, error %v", tok, err)
+	}
+	for _, bad := range [][2]string{{"user", "nope"}, {"nope", "pass"}, {"", ""}} {
+		if _, _, err := Authorize(httptest.NewRequest("GET", login(bad[0], bad[1]), nil)); err != ErrLogin {
+			t.Errorf("login %q/%q: got %v, want ErrLogin", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestAuthorizeToken(t *testing.T) {
+	noStore(t)
+	t.Setenv("PLAYLIST_SECRET", "secret")
+	t.Setenv("POLICY_PROFILES", `{"family": {"tier": "subscriber"}}`)
+	// The shared credentials no longer work once tokens are enabled.
+	t.Setenv("XTREAM_USERNAME", "user")
+	t.Setenv("XTREAM_PASSWORD", "pass")
+
+	tok, _, err := token.Issue("alice", "family", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, got, err := Authorize(httptest.NewRequest("GET", login("alice", tok)+"&profile=free", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != tok || p.Name != "family" {
+		t.Errorf("token login: got profile %q and token %q, want family and the token", p.Name, got)
+	}
+
+	for _, bad := range [][2]string{{"bob", tok}, {"alice", tok + "x"}, {"user", "pass"}} {
+		if _, _, err := Authorize(httptest.NewRequest("GET", login(bad[0], bad[1]), nil)); err != ErrLogin {
+			t.Errorf("login %q/%q: got %v, want ErrLogin", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestLiveStreams(t *testing.T) {
+	channels := []source.Channel{
+		{ID: "tvj", Name: "TVJ", Group: "News", Number: 1},
+		{ID: "cvm", Name: "CVM", Group: "Sports", Number: 2},
+		{ID: "sky", Name: "Sky", Group: "News", Number: 3},
+	}
+	all := LiveStreams(channels, "")
+	if len(all) != 3 || all[0].StreamID != StreamID("tvj") || all[0].EPGChannelID != "tvj" {
+		t.Fatalf("LiveStreams: got %+v", all)
+	}
+	news := LiveStreams(channels, CategoryID("News"))
+	if len(news) != 2 || news[0].Name != "TVJ" || news[1].Name != "Sky" {
+		t.Errorf("News category: got %+v", news)
+	}
+
+	cats := LiveCategories(channels)
+	if len(cats) != 2 || cats[0].CategoryName != "News" || cats[1].CategoryName != "Sports" {
+		t.Errorf("LiveCategories: got %+v", cats)
+	}
+	if ch, ok := Find(channels, StreamID("cvm")); !ok || ch.ID != "cvm" {
+		t.Errorf("Find cvm: got %+v, %t", ch, ok)
+	}
+}
+
+func TestLiveStreamsNumFromOne(t *testing.T) {
+	// CHANNEL_NUMBER_START defaults to 0.
+	channels := []source.Channel{
+		{ID: "tvj", Group: "News", Number: 0},
+		{ID: "cvm", Group: "Sports", Number: 1},
+		{ID: "sky", Group: "News", Number: 2},
+	}
+	for i, s := range LiveStreams(channels, "") {
+		if s.Num != i+1 {
+			t.Errorf("%s: got num %d, want %d", s.EPGChannelID, s.Num, i+1)
+		}
+	}
+	sports := LiveStreams(channels, CategoryID("Sports"))
+	if len(sports) != 1 || sports[0].Num != 2 {
+		t.Errorf("Sports category: got %+v, want cvm as num 2", sports)
+	}
+}
+
+func TestNewShortEPG(t *testing.T) {
+	now := time.Date(2026, 10, 18, 19, 30, 0, 0, time.UTC)
+	ch := source.Channel{ID: "tvj", Programmes: []source.Programme{
+		{Title: "Past", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)},
+		{Title: "Now", Start: now.Add(-30 * time.Minute), End: now.Add(30 * time.Minute)},
+		{Title: "Next", Start: now.Add(30 * time.Minute), End: now.Add(time.Hour)},
+	}}
+	epg := NewShortEPG(ch, 1, now)
+	if len(epg.Listings) != 1 {
+		t.Fatalf("got %d listings, want 1", len(epg.Listings))
+	}
+	l := epg.Listings[0]
+	if title, _ := base64.StdEncoding.DecodeString(l.Title); string(title) != "Now" {
+		t.Errorf("title: got %q, want Now", title)
+	}
+	if l.Start != "2026-10-18 19:00:00" || l.StopTimestamp != now.Add(30*time.Minute).Unix() {
+		t.Errorf("times: got %q and %d", l.Start, l.StopTimestamp)
+	}
+}